@@ -0,0 +1,175 @@
+package http_runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+// These tests are meant to be run with the race detector: go test -race
+func TestConcurrentRequestOptions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Millisecond * 300):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHttpRunner, err := NewProxyHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runners := map[string]IHttpRunner{
+		"DirectHttpRunner": directHttpRunner,
+		"ProxyHttpRunner":  proxyHttpRunner,
+	}
+
+	for name, runner := range runners {
+		runner := runner
+
+		t.Run("TestConcurrentRequestOptions-"+name, func(t *testing.T) {
+			var wg sync.WaitGroup
+
+			for i := 0; i < 20; i++ {
+				wg.Add(3)
+
+				go func() {
+					defer wg.Done()
+
+					htmlRequest := NewHtmlRequestOptions(server.URL + "/redirect")
+					htmlRequest.SetFollowRedirectOption(false)
+
+					response, err := runner.GetHtml(htmlRequest)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if got := response.StatusCode(); got != http.StatusFound {
+						t.Errorf("response.StatusCode() = %v, want %v", got, http.StatusFound)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+
+					// no redirect option set, the html default is to follow
+					htmlRequest := NewHtmlRequestOptions(server.URL + "/redirect")
+
+					response, err := runner.GetHtml(htmlRequest)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if got := response.StatusCode(); got != http.StatusOK {
+						t.Errorf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+					}
+				}()
+				go func(i int) {
+					defer wg.Done()
+
+					jsonRequest := NewJsonRequestOptions(server.URL + "/slow")
+					jsonRequest.SetRetryOption(0)
+
+					if i%2 == 0 {
+						jsonRequest.SetTimeoutOption(time.Millisecond * 50)
+
+						if _, err := runner.GetJson(jsonRequest); err == nil {
+							t.Error("runner.GetJson() error = nil, want timeout")
+						}
+					} else {
+						jsonRequest.SetTimeoutOption(time.Second * 10)
+
+						response, err := runner.GetJson(jsonRequest)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if got := response.StatusCode(); got != http.StatusOK {
+							t.Errorf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+						}
+					}
+				}(i)
+			}
+
+			wg.Wait()
+		})
+	}
+}
+
+func TestRetryOptionIsPerRequest(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+
+		// drop the connection without answering so the client sees a transport error
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for path, retryCount := range map[string]int{"/none": 0, "/one": 1, "/three": 3} {
+		wg.Add(1)
+
+		go func(path string, retryCount int) {
+			defer wg.Done()
+
+			jsonRequest := NewJsonRequestOptions(server.URL + path)
+			jsonRequest.SetRetryOption(retryCount)
+
+			if _, err := directHttpRunner.GetJson(jsonRequest); err == nil {
+				t.Errorf("directHttpRunner.GetJson(%v) error = nil, want error", path)
+			}
+		}(path, retryCount)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for path, want := range map[string]int{"/none": 1, "/one": 2, "/three": 4} {
+		if got := hits[path]; got != want {
+			t.Errorf("attempts for %v = %v, want %v", path, got, want)
+		}
+	}
+}
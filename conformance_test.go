@@ -0,0 +1,168 @@
+package http_runner
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+	"github.com/go-resty/resty/v2"
+)
+
+// TestRunnerConformance runs every IHttpRunner method against every runner preset,
+// which all share the same request pipeline and must behave the same.
+func TestRunnerConformance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("x-method", r.Method)
+		w.Header().Set("x-content-type", r.Header.Get("Content-Type"))
+		w.Header().Set("x-test", r.Header.Get("x-test"))
+		w.Header().Set("x-accept-language", r.Header.Get("accept-language"))
+		if cookie, err := r.Cookie("test"); err == nil {
+			w.Header().Set("x-cookie", cookie.Value)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHttpRunner, err := NewProxyHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookie := &http.Cookie{Name: "test", Value: "cookie", Domain: "127.0.0.1"}
+	headers := map[string]string{"x-test": "true"}
+
+	newJsonRequest := func() IJsonRequestOptions {
+		jsonRequest := NewJsonRequestOptions(server.URL)
+		jsonRequest.SetHeaders(headers)
+		jsonRequest.SetValue([]byte(`{"test":true}`))
+
+		return jsonRequest
+	}
+	newHtmlRequest := func() IHtmlRequestOptions {
+		htmlRequest := NewHtmlRequestOptions(server.URL)
+		htmlRequest.SetHeaders(headers)
+
+		return htmlRequest
+	}
+	newFormRequest := func() IFormRequestOptions {
+		formRequest := NewFormRequestOptions(server.URL)
+		formRequest.SetHeaders(headers)
+		formRequest.SetValues(map[string]string{"test": "true"})
+
+		return formRequest
+	}
+
+	tests := []struct {
+		name        string
+		send        func(runner IHttpRunner) (*resty.Response, error)
+		method      string
+		contentType string
+		body        string
+	}{
+		{"GetJson", func(runner IHttpRunner) (*resty.Response, error) { return runner.GetJson(newJsonRequest(), cookie) }, http.MethodGet, "application/json", ""},
+		{"GetHtml", func(runner IHttpRunner) (*resty.Response, error) { return runner.GetHtml(newHtmlRequest(), cookie) }, http.MethodGet, "", ""},
+		{"PostJson", func(runner IHttpRunner) (*resty.Response, error) { return runner.PostJson(newJsonRequest(), cookie) }, http.MethodPost, "application/json", `{"test":true}`},
+		{"PutJson", func(runner IHttpRunner) (*resty.Response, error) { return runner.PutJson(newJsonRequest(), cookie) }, http.MethodPut, "application/json", `{"test":true}`},
+		{"PatchJson", func(runner IHttpRunner) (*resty.Response, error) { return runner.PatchJson(newJsonRequest(), cookie) }, http.MethodPatch, "application/json", `{"test":true}`},
+		{"DeleteJson", func(runner IHttpRunner) (*resty.Response, error) { return runner.DeleteJson(newJsonRequest(), cookie) }, http.MethodDelete, "application/json", `{"test":true}`},
+		{"PostForm", func(runner IHttpRunner) (*resty.Response, error) { return runner.PostForm(newFormRequest(), cookie) }, http.MethodPost, "application/x-www-form-urlencoded", "test=true"},
+		{"Head", func(runner IHttpRunner) (*resty.Response, error) { return runner.Head(newHtmlRequest(), cookie) }, http.MethodHead, "", ""},
+		{"Options", func(runner IHttpRunner) (*resty.Response, error) { return runner.Options(newHtmlRequest(), cookie) }, http.MethodOptions, "", ""},
+		{"Do", func(runner IHttpRunner) (*resty.Response, error) { return runner.Do("PURGE", newJsonRequest(), cookie) }, "PURGE", "application/json", `{"test":true}`},
+	}
+
+	runners := map[string]IHttpRunner{
+		"DirectHttpRunner": directHttpRunner,
+		"ProxyHttpRunner":  proxyHttpRunner,
+	}
+
+	for runnerName, runner := range runners {
+		for _, tt := range tests {
+			t.Run("TestRunnerConformance-"+runnerName+"-"+tt.name, func(t *testing.T) {
+				response, err := tt.send(runner)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := response.StatusCode(); got != http.StatusOK {
+					t.Errorf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+				}
+				if got := response.Header().Get("x-method"); got != tt.method {
+					t.Errorf("method = %v, want %v", got, tt.method)
+				}
+				if got := response.Header().Get("x-content-type"); got != tt.contentType {
+					t.Errorf("Content-Type = %v, want %v", got, tt.contentType)
+				}
+				if got := response.Header().Get("x-test"); got != "true" {
+					t.Errorf("x-test header = %v, want %v", got, "true")
+				}
+				if got := response.Header().Get("x-accept-language"); got != DefaultHeaders["accept-language"] {
+					t.Errorf("accept-language header = %v, want %v", got, DefaultHeaders["accept-language"])
+				}
+				if got := response.Header().Get("x-cookie"); got != cookie.Value {
+					t.Errorf("cookie = %v, want %v", got, cookie.Value)
+				}
+				if got := string(response.Body()); got != tt.body {
+					t.Errorf("body = %v, want %v", got, tt.body)
+				}
+			})
+		}
+
+		t.Run("TestRunnerConformance-"+runnerName+"-GetFile", func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "file.bin")
+
+			fileRequest := NewFileRequestOptions(server.URL, filePath)
+			fileRequest.SetHeaders(headers)
+
+			response, err := runner.Do(http.MethodPost, fileRequest, cookie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Header().Get("x-method"); got != http.MethodPost {
+				t.Errorf("method = %v, want %v", got, http.MethodPost)
+			}
+			if got := response.Header().Get("x-cookie"); got != cookie.Value {
+				t.Errorf("cookie = %v, want %v", got, cookie.Value)
+			}
+
+			if _, err := runner.GetFile(fileRequest, cookie); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filePath); err != nil {
+				t.Fatal(err)
+			}
+		})
+		t.Run("TestRunnerConformance-"+runnerName+"-PostFormFiles", func(t *testing.T) {
+			formRequest := newFormRequest()
+			formRequest.SetFiles(map[string]FileInfo{
+				"upload": NewFileInfoFromBytes("test.txt", []byte("content")),
+			})
+
+			response, err := runner.PostForm(formRequest, cookie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Header().Get("x-content-type"); !strings.HasPrefix(got, "multipart/form-data") {
+				t.Errorf("Content-Type = %v, want multipart/form-data", got)
+			}
+			if got := string(response.Body()); !strings.Contains(got, "content") || !strings.Contains(got, `name="test"`) {
+				t.Errorf("body = %v, want the file and the values", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package http_runner
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// contentDecoders make the readers of the content codings orderedTransport decodes.
+var contentDecoders = map[string]func(body io.Reader) (io.ReadCloser, error){
+	"gzip": func(body io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(body)
+	},
+	"deflate": func(body io.Reader) (io.ReadCloser, error) {
+		return zlib.NewReader(body)
+	},
+	"br": func(body io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(body)), nil
+	},
+	"zstd": func(body io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+	},
+}
+
+// decodeResponse decodes the body of resp when it is compressed with a single coding the
+// Accept-Encoding header of req lists, as net/http does for the gzip it asks for itself.
+func decodeResponse(req *http.Request, resp *http.Response) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	newDecoder, ok := contentDecoders[encoding]
+	if !ok || !acceptsEncoding(req.Header.Get("Accept-Encoding"), encoding) {
+		return
+	}
+
+	resp.Body = &decodedBody{raw: resp.Body, newDecoder: newDecoder}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+func acceptsEncoding(acceptEncoding string, encoding string) bool {
+	for _, accepted := range strings.Split(acceptEncoding, ",") {
+		name, _, _ := strings.Cut(accepted, ";")
+		if strings.EqualFold(strings.TrimSpace(name), encoding) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodedBody decodes raw, the decoder is made on the first read so that an error reading the
+// header of the coding is returned by Read.
+type decodedBody struct {
+	raw        io.ReadCloser
+	newDecoder func(body io.Reader) (io.ReadCloser, error)
+	decoder    io.ReadCloser
+	err        error
+}
+
+func (d *decodedBody) Read(p []byte) (int, error) {
+	if d.decoder == nil && d.err == nil {
+		d.decoder, d.err = d.newDecoder(d.raw)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	n, err := d.decoder.Read(p)
+	if err == io.EOF {
+		// the coding may end before the raw body does, reading raw to its end lets the
+		// connection be reused
+		if _, drainErr := io.Copy(io.Discard, io.LimitReader(d.raw, 4<<10)); drainErr != nil {
+			err = drainErr
+		}
+	}
+
+	return n, err
+}
+
+func (d *decodedBody) Close() error {
+	if d.decoder != nil {
+		d.decoder.Close()
+	}
+
+	return d.raw.Close()
+}
@@ -0,0 +1,70 @@
+package http_runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	encode := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buffer bytes.Buffer
+		writer := newWriter(&buffer)
+		writer.Write([]byte("decoded"))
+		writer.Close()
+
+		return buffer.Bytes()
+	}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentCoding  string
+		body           []byte
+		want           string
+	}{
+		{"Gzip", "gzip, deflate, br, zstd", "gzip", encode(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }), "decoded"},
+		{"Deflate", "gzip, deflate", "deflate", encode(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }), "decoded"},
+		{"Brotli", "br", "br", encode(func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }), "decoded"},
+		{"Zstd", "gzip;q=1.0, zstd;q=0.9", "zstd", encode(func(w io.Writer) io.WriteCloser {
+			writer, _ := zstd.NewWriter(w)
+			return writer
+		}), "decoded"},
+		{"NotAsked", "br", "gzip", []byte("raw"), "raw"},
+		{"Unknown", "compress", "compress", []byte("raw"), "raw"},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestDecodeResponse-"+tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			resp := &http.Response{
+				Header:        http.Header{"Content-Encoding": {tt.contentCoding}},
+				Body:          io.NopCloser(bytes.NewReader(tt.body)),
+				ContentLength: int64(len(tt.body)),
+			}
+			decodeResponse(req, resp)
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("body = %q, want %q", body, tt.want)
+			}
+			if decoded := tt.want == "decoded"; resp.Uncompressed != decoded || (len(resp.Header.Get("Content-Encoding")) <= 0) != decoded {
+				t.Errorf("resp.Uncompressed = %v, Content-Encoding = %q", resp.Uncompressed, resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
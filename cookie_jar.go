@@ -0,0 +1,52 @@
+package http_runner
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// NewCookieJar returns an RFC 6265 cookie jar restricted by the public suffix list,
+// suitable for IHttpRunner.WithCookieJar.
+func NewCookieJar() (http.CookieJar, error) {
+	return cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+}
+
+type cookieJarKey struct{}
+
+// cookieJarTransport stores the Set-Cookie headers of every response (redirect hops
+// included) in the jar carried by the request context, and sends the stored cookies
+// back on the following requests. Requests without a jar pass through untouched.
+type cookieJarTransport struct {
+	base http.RoundTripper
+}
+
+func (c *cookieJarTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jar, ok := req.Context().Value(cookieJarKey{}).(http.CookieJar)
+	if !ok || jar == nil {
+		return c.base.RoundTrip(req)
+	}
+
+	if cookies := jar.Cookies(req.URL); len(cookies) > 0 {
+		req = req.Clone(req.Context())
+
+		for _, cookie := range cookies {
+			// cookies passed explicitly with the request win over the stored ones
+			if _, err := req.Cookie(cookie.Name); err == http.ErrNoCookie {
+				req.AddCookie(cookie)
+			}
+		}
+	}
+
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		jar.SetCookies(req.URL, cookies)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,212 @@
+package http_runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestWithCookieJar(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "short", Value: "lived", Path: "/", MaxAge: -1})
+		http.Redirect(w, r, "/home", http.StatusFound)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("short"); err == nil {
+			w.WriteHeader(http.StatusBadRequest) // expired cookie sent back
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestWithCookieJar-Session", func(t *testing.T) {
+		jar, err := NewCookieJar()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		session := directHttpRunner.WithCookieJar(jar)
+
+		// the cookie set by the redirect response must reach the redirect target
+		response, err := session.GetHtml(NewHtmlRequestOptions(server.URL + "/login"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.StatusCode(); got != http.StatusOK {
+			t.Fatalf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+		}
+
+		response, err = session.GetHtml(NewHtmlRequestOptions(server.URL + "/home"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.StatusCode(); got != http.StatusOK {
+			t.Fatalf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+		}
+	})
+	t.Run("TestWithCookieJar-Runner", func(t *testing.T) {
+		// the runner keeps cookies in a jar of its own, like a resty client does
+		runner, err := NewDirectHttpRunner(directDialer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + "/login")); err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + "/home"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.StatusCode(); got != http.StatusOK {
+			t.Fatalf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+		}
+	})
+	t.Run("TestWithCookieJar-NoJar", func(t *testing.T) {
+		// a nil jar keeps no cookies, not even those of a redirect
+		runner, err := NewDirectHttpRunner(directDialer, WithCookieJar(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{"/login", "/home"} {
+			response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.StatusCode(); got != http.StatusUnauthorized {
+				t.Fatalf("response.StatusCode() of %v = %v, want %v", path, got, http.StatusUnauthorized)
+			}
+		}
+	})
+	t.Run("TestWithCookieJar-Isolation", func(t *testing.T) {
+		// neither the runner itself nor another session see the cookies of the first session
+		for _, runner := range []IHttpRunner{directHttpRunner, directHttpRunner.WithCookieJar(nil)} {
+			response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + "/home"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.StatusCode(); got != http.StatusUnauthorized {
+				t.Fatalf("response.StatusCode() = %v, want %v", got, http.StatusUnauthorized)
+			}
+		}
+	})
+}
+
+func TestCookieMatchesUrl(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		url    string
+		want   bool
+	}{
+		{"ExactDomain", &http.Cookie{Name: "a", Domain: "example.com"}, "https://example.com/", true},
+		{"DotDomain", &http.Cookie{Name: "a", Domain: ".example.com"}, "https://example.com/", true},
+		{"SubDomain", &http.Cookie{Name: "a", Domain: "example.com"}, "https://www.example.com/", true},
+		{"DomainCase", &http.Cookie{Name: "a", Domain: "Example.COM"}, "https://WWW.example.com/", true},
+		{"SuffixWithoutDot", &http.Cookie{Name: "a", Domain: "example.com"}, "https://badexample.com/", false},
+		{"ParentDomain", &http.Cookie{Name: "a", Domain: "www.example.com"}, "https://example.com/", false},
+		{"OtherDomain", &http.Cookie{Name: "a", Domain: "example.com"}, "https://example.org/", false},
+		{"EmptyDomain", &http.Cookie{Name: "a"}, "https://example.com/", false},
+		{"IpExact", &http.Cookie{Name: "a", Domain: "127.0.0.1"}, "http://127.0.0.1:8080/", true},
+		{"IpSuffix", &http.Cookie{Name: "a", Domain: "0.0.1"}, "http://127.0.0.1/", false},
+		{"PortIgnored", &http.Cookie{Name: "a", Domain: "example.com"}, "https://example.com:8443/", true},
+		{"RootPath", &http.Cookie{Name: "a", Domain: "example.com", Path: "/"}, "https://example.com/any/path", true},
+		{"EmptyPath", &http.Cookie{Name: "a", Domain: "example.com"}, "https://example.com/any/path", true},
+		{"ExactPath", &http.Cookie{Name: "a", Domain: "example.com", Path: "/api"}, "https://example.com/api", true},
+		{"SubPath", &http.Cookie{Name: "a", Domain: "example.com", Path: "/api"}, "https://example.com/api/v1", true},
+		{"SubPathTrailingSlash", &http.Cookie{Name: "a", Domain: "example.com", Path: "/api/"}, "https://example.com/api/v1", true},
+		{"PathPrefixWithoutSlash", &http.Cookie{Name: "a", Domain: "example.com", Path: "/api"}, "https://example.com/apiv1", false},
+		{"ParentPath", &http.Cookie{Name: "a", Domain: "example.com", Path: "/api/v1"}, "https://example.com/api", false},
+		{"NoRequestPath", &http.Cookie{Name: "a", Domain: "example.com", Path: "/api"}, "https://example.com", false},
+		{"SecureOverHttps", &http.Cookie{Name: "a", Domain: "example.com", Secure: true}, "https://example.com/", true},
+		{"SecureOverHttp", &http.Cookie{Name: "a", Domain: "example.com", Secure: true}, "http://example.com/", false},
+		{"NotExpired", &http.Cookie{Name: "a", Domain: "example.com", Expires: now.Add(time.Hour)}, "https://example.com/", true},
+		{"Expired", &http.Cookie{Name: "a", Domain: "example.com", Expires: now.Add(-time.Hour)}, "https://example.com/", false},
+		{"NegativeMaxAge", &http.Cookie{Name: "a", Domain: "example.com", MaxAge: -1}, "https://example.com/", false},
+		{"MaxAgeOverExpires", &http.Cookie{Name: "a", Domain: "example.com", MaxAge: 60, Expires: now.Add(-time.Hour)}, "https://example.com/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestCookieMatchesUrl-"+tt.name, func(t *testing.T) {
+			parsedUrl, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := cookieMatchesUrl(tt.cookie, parsedUrl, now); got != tt.want {
+				t.Errorf("cookieMatchesUrl(%v, %v) = %v, want %v", tt.cookie, tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrateCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, cookie := range r.Cookies() {
+			w.Header().Add("x-cookie", cookie.Name+"="+cookie.Value)
+		}
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := directHttpRunner.GetHtml(NewHtmlRequestOptions(server.URL+"/api/v1"),
+		&http.Cookie{Name: "match", Value: "1", Domain: "127.0.0.1", Path: "/api"},
+		&http.Cookie{Name: "duplicate", Value: "first", Domain: "127.0.0.1", Path: "/"},
+		&http.Cookie{Name: "duplicate", Value: "second", Domain: "127.0.0.1", Path: "/"},
+		&http.Cookie{Name: "other-host", Value: "1", Domain: "example.com"},
+		&http.Cookie{Name: "secure", Value: "1", Domain: "127.0.0.1", Secure: true},
+		&http.Cookie{Name: "other-path", Value: "1", Domain: "127.0.0.1", Path: "/web"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"match=1": true, "duplicate=first": true}
+
+	got := response.Header().Values("x-cookie")
+	if len(got) != len(want) {
+		t.Fatalf("sent cookies = %v, want %v", got, want)
+	}
+	for _, cookie := range got {
+		if !want[cookie] {
+			t.Fatalf("sent cookies = %v, want %v", got, want)
+		}
+	}
+}
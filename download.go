@@ -0,0 +1,353 @@
+package http_runner
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ChecksumAlgorithm names the hash a downloaded file is checked with.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumSHA256 ChecksumAlgorithm = "sha-256"
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+)
+
+// Checksum is the expected hash of a downloaded file, Value is hex encoded.
+type Checksum struct {
+	Algorithm ChecksumAlgorithm
+	Value     string
+}
+
+// ErrChecksumMismatch is returned when a downloaded file does not have the expected checksum,
+// either the one of the request options or the one of a Digest or Content-MD5 header.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// fileDownload writes a GetFile response to filePath. The body is written to a .part file
+// next to it that is renamed once complete and verified, so filePath never holds a truncated
+// file. The validator of the part (ETag or Last-Modified) is kept in a .part.validator file,
+// which lets a later attempt or call resume the part with Range and If-Range.
+type fileDownload struct {
+	filePath string
+	resume   bool
+	checksum *Checksum
+	segments int
+	transfer transferSettings
+}
+
+func newFileDownload(requestOptions IFileRequestOptions, transfer transferSettings) fileDownload {
+	download := fileDownload{
+		filePath: requestOptions.FilePath(),
+		resume:   true,
+		transfer: transfer,
+	}
+
+	if requestOptions.IsResumeOptionSet() {
+		download.resume = requestOptions.ResumeOption()
+	}
+	if requestOptions.IsChecksumOptionSet() {
+		checksum := requestOptions.ChecksumOption()
+		download.checksum = &checksum
+	}
+	if requestOptions.IsSegmentsOptionSet() {
+		download.segments = requestOptions.SegmentsOption()
+	}
+
+	return download
+}
+
+func (d fileDownload) partPath() string {
+	return d.filePath + ".part"
+}
+
+func (d fileDownload) validatorPath() string {
+	return d.filePath + ".part.validator"
+}
+
+func (d fileDownload) removePart() {
+	_ = os.Remove(d.partPath())
+	_ = os.Remove(d.validatorPath())
+}
+
+// prepare asks for the rest of a part left by an earlier attempt and returns its size,
+// or zero when the download starts from the beginning.
+func (d fileDownload) prepare(request *resty.Request) int64 {
+	if !d.resume || len(request.Header.Get("Range")) > 0 {
+		return 0
+	}
+
+	info, err := os.Stat(d.partPath())
+	if err != nil || info.Size() <= 0 {
+		return 0
+	}
+
+	validator, err := os.ReadFile(d.validatorPath())
+	if err != nil || len(validator) <= 0 {
+		return 0
+	}
+
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+	request.Header.Set("If-Range", string(validator))
+
+	return info.Size()
+}
+
+// handle writes the body of response to the part and moves the part to the file path once complete.
+// Responses that are not successful keep their body and leave the part untouched.
+func (d fileDownload) handle(method string, response *resty.Response, offset int64) error {
+	rawBody := response.RawBody()
+	defer rawBody.Close()
+
+	statusCode := response.StatusCode()
+
+	if statusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		// the part may already hold the whole file
+		if _, _, size, ok := parseContentRange(response.Header().Get("Content-Range")); ok && size == offset {
+			return d.complete(nil)
+		}
+
+		d.removePart()
+
+		return fmt.Errorf("resuming %v at %d was refused", d.filePath, offset)
+	}
+
+	if !response.IsSuccess() || method == resty.MethodHead {
+		body, err := io.ReadAll(rawBody)
+		response.SetBody(body)
+
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.filePath), 0755); err != nil {
+		return err
+	}
+
+	expectedSize := int64(-1)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if statusCode == http.StatusPartialContent && offset > 0 {
+		start, _, size, ok := parseContentRange(response.Header().Get("Content-Range"))
+		if !ok || start != offset {
+			d.removePart()
+
+			return fmt.Errorf("resuming %v at %d got Content-Range %q", d.filePath, offset, response.Header().Get("Content-Range"))
+		}
+
+		expectedSize = size
+		flags = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+		if response.RawResponse.ContentLength >= 0 {
+			expectedSize = response.RawResponse.ContentLength
+		}
+
+		if err := d.saveValidator(response.Header()); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(d.partPath(), flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(file, newTransferReader(response.Request.Context(), rawBody, d.transfer, offset, expectedSize))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if expectedSize >= 0 && offset+written != expectedSize {
+		return fmt.Errorf("download of %v stopped at %d of %d bytes: %w", d.filePath, offset+written, expectedSize, io.ErrUnexpectedEOF)
+	}
+
+	return d.complete(response)
+}
+
+// rangeValidator returns the ETag or Last-Modified to send with If-Range. Weak ETags can
+// not be used with If-Range.
+func rangeValidator(header http.Header) string {
+	validator := header.Get("ETag")
+	if len(validator) <= 0 || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+
+	return validator
+}
+
+func (d fileDownload) saveValidator(header http.Header) error {
+	validator := rangeValidator(header)
+
+	if !d.resume || len(validator) <= 0 {
+		if err := os.Remove(d.validatorPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	return os.WriteFile(d.validatorPath(), []byte(validator), 0644)
+}
+
+// complete verifies the part and renames it to the file path. response is nil when
+// the part was completed by an earlier attempt.
+func (d fileDownload) complete(response *resty.Response) error {
+	if err := d.verify(response); err != nil {
+		d.removePart()
+
+		return err
+	}
+
+	if err := os.Rename(d.partPath(), d.filePath); err != nil {
+		return err
+	}
+
+	_ = os.Remove(d.validatorPath())
+
+	return nil
+}
+
+type expectedChecksum struct {
+	algorithm ChecksumAlgorithm
+	sum       []byte
+	source    string
+}
+
+func (d fileDownload) verify(response *resty.Response) error {
+	var checksums []expectedChecksum
+
+	if d.checksum != nil {
+		sum, err := hex.DecodeString(d.checksum.Value)
+		if err != nil {
+			return fmt.Errorf("checksum %q is not hex encoded: %w", d.checksum.Value, err)
+		}
+
+		checksums = append(checksums, expectedChecksum{algorithm: ChecksumAlgorithm(strings.ToLower(string(d.checksum.Algorithm))), sum: sum, source: "request options"})
+	}
+
+	if response != nil {
+		checksums = append(checksums, headerChecksums(response)...)
+	}
+
+	for _, checksum := range checksums {
+		got, err := fileChecksum(d.partPath(), checksum.algorithm)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(got, checksum.sum) {
+			return fmt.Errorf("%w: %v of %v is %x, %v want %x", ErrChecksumMismatch, checksum.algorithm, d.filePath, got, checksum.source, checksum.sum)
+		}
+	}
+
+	return nil
+}
+
+// headerChecksums reads the Digest header, which covers the whole file, and Content-MD5,
+// which only covers the body of this response, so it is ignored for partial content.
+func headerChecksums(response *resty.Response) []expectedChecksum {
+	var checksums []expectedChecksum
+
+	for _, digest := range strings.Split(response.Header().Get("Digest"), ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+		if !found {
+			continue
+		}
+
+		algorithm = strings.ToLower(algorithm)
+		if algorithm != string(ChecksumSHA256) && algorithm != string(ChecksumMD5) {
+			continue
+		}
+
+		if sum, err := base64.StdEncoding.DecodeString(value); err == nil {
+			checksums = append(checksums, expectedChecksum{algorithm: ChecksumAlgorithm(algorithm), sum: sum, source: "Digest"})
+		}
+	}
+
+	if contentMd5 := response.Header().Get("Content-MD5"); len(contentMd5) > 0 && response.StatusCode() != http.StatusPartialContent {
+		if sum, err := base64.StdEncoding.DecodeString(contentMd5); err == nil {
+			checksums = append(checksums, expectedChecksum{algorithm: ChecksumMD5, sum: sum, source: "Content-MD5"})
+		}
+	}
+
+	return checksums
+}
+
+func fileChecksum(filePath string, algorithm ChecksumAlgorithm) ([]byte, error) {
+	var hasher hash.Hash
+
+	switch algorithm {
+	case ChecksumSHA256:
+		hasher = sha256.New()
+	case ChecksumMD5:
+		hasher = md5.New()
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+
+	return hasher.Sum(nil), nil
+}
+
+// parseContentRange parses "bytes first-last/size" and "bytes */size", size is -1 when unknown.
+func parseContentRange(value string) (first, last, size int64, ok bool) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, 0, false
+	}
+	value = strings.TrimPrefix(value, "bytes ")
+
+	byteRange, sizeValue, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	size = -1
+	if sizeValue != "*" {
+		var err error
+		if size, err = strconv.ParseInt(sizeValue, 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+
+	if byteRange == "*" {
+		return -1, -1, size, true
+	}
+
+	firstValue, lastValue, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	first, firstErr := strconv.ParseInt(firstValue, 10, 64)
+	last, lastErr := strconv.ParseInt(lastValue, 10, 64)
+	if firstErr != nil || lastErr != nil {
+		return 0, 0, 0, false
+	}
+
+	return first, last, size, true
+}
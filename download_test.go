@@ -0,0 +1,213 @@
+package http_runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestGetFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 64*1024)
+	contentSum := sha256.Sum256(content)
+
+	var (
+		mutex      sync.Mutex
+		hits       int
+		ranges     []string
+		digest     string
+		abortFirst bool
+		statusCode int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		hits++
+		hit := hits
+		ranges = append(ranges, r.Header.Get("Range"))
+		mutex.Unlock()
+
+		if statusCode != 0 {
+			w.WriteHeader(statusCode)
+			_, _ = w.Write([]byte("not here"))
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		if len(digest) > 0 {
+			w.Header().Set("Digest", digest)
+		}
+
+		// the first attempt drops the connection half way through the body
+		if abortFirst && hit == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer, WithRetryCount(1), WithRetryPolicy(RetryPolicy{MinBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reset := func() {
+		hits, ranges, digest, abortFirst, statusCode = 0, nil, "", false, 0
+	}
+
+	t.Run("TestGetFileResume-Resume", func(t *testing.T) {
+		reset()
+		abortFirst = true
+
+		filePath := filepath.Join(t.TempDir(), "file.bin")
+
+		fileRequest := NewFileRequestOptions(server.URL, filePath)
+		fileRequest.SetChecksumOption(Checksum{Algorithm: ChecksumSHA256, Value: hex.EncodeToString(contentSum[:])})
+
+		if _, err := directHttpRunner.GetFile(fileRequest); err != nil {
+			t.Fatal(err)
+		}
+
+		if hits != 2 {
+			t.Errorf("attempts = %v, want %v", hits, 2)
+		}
+		if want := "bytes=" + strconv.Itoa(len(content)/2) + "-"; ranges[1] != want {
+			t.Errorf("second attempt Range = %v, want %v", ranges[1], want)
+		}
+
+		got, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("downloaded %v bytes, want the %v bytes of the file", len(got), len(content))
+		}
+		for _, leftover := range []string{filePath + ".part", filePath + ".part.validator"} {
+			if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+				t.Errorf("%v is left after the download", leftover)
+			}
+		}
+	})
+	t.Run("TestGetFileResume-ResumeDisabled", func(t *testing.T) {
+		reset()
+		abortFirst = true
+
+		filePath := filepath.Join(t.TempDir(), "file.bin")
+
+		fileRequest := NewFileRequestOptions(server.URL, filePath)
+		fileRequest.SetResumeOption(false)
+
+		if _, err := directHttpRunner.GetFile(fileRequest); err != nil {
+			t.Fatal(err)
+		}
+		if ranges[1] != "" {
+			t.Errorf("second attempt Range = %v, want none", ranges[1])
+		}
+		if got, _ := os.ReadFile(filePath); !bytes.Equal(got, content) {
+			t.Errorf("downloaded %v bytes, want the %v bytes of the file", len(got), len(content))
+		}
+	})
+	t.Run("TestGetFileResume-Truncated", func(t *testing.T) {
+		reset()
+		abortFirst = true
+
+		filePath := filepath.Join(t.TempDir(), "file.bin")
+
+		fileRequest := NewFileRequestOptions(server.URL, filePath)
+		fileRequest.SetRetryOption(0)
+
+		if _, err := directHttpRunner.GetFile(fileRequest); err == nil {
+			t.Fatal("directHttpRunner.GetFile() error = nil, want the dropped connection")
+		}
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			t.Errorf("truncated download was written to %v", filePath)
+		}
+
+		// a later call picks up the part
+		reset()
+
+		if _, err := directHttpRunner.GetFile(fileRequest); err != nil {
+			t.Fatal(err)
+		}
+		if want := "bytes=" + strconv.Itoa(len(content)/2) + "-"; ranges[0] != want {
+			t.Errorf("Range = %v, want %v", ranges[0], want)
+		}
+		if got, _ := os.ReadFile(filePath); !bytes.Equal(got, content) {
+			t.Errorf("downloaded %v bytes, want the %v bytes of the file", len(got), len(content))
+		}
+	})
+	t.Run("TestGetFileResume-ChecksumMismatch", func(t *testing.T) {
+		reset()
+
+		filePath := filepath.Join(t.TempDir(), "file.bin")
+
+		fileRequest := NewFileRequestOptions(server.URL, filePath)
+		fileRequest.SetRetryOption(0)
+		fileRequest.SetChecksumOption(Checksum{Algorithm: ChecksumMD5, Value: "00000000000000000000000000000000"})
+
+		if _, err := directHttpRunner.GetFile(fileRequest); !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("directHttpRunner.GetFile() error = %v, want %v", err, ErrChecksumMismatch)
+		}
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			t.Errorf("download with a wrong checksum was written to %v", filePath)
+		}
+	})
+	t.Run("TestGetFileResume-Digest", func(t *testing.T) {
+		reset()
+		digest = "SHA-256=" + base64.StdEncoding.EncodeToString(contentSum[:])
+
+		filePath := filepath.Join(t.TempDir(), "file.bin")
+
+		if _, err := directHttpRunner.GetFile(NewFileRequestOptions(server.URL, filePath)); err != nil {
+			t.Fatal(err)
+		}
+
+		reset()
+		digest = "SHA-256=" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+		fileRequest := NewFileRequestOptions(server.URL, filePath+".other")
+		fileRequest.SetRetryOption(0)
+
+		if _, err := directHttpRunner.GetFile(fileRequest); !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("directHttpRunner.GetFile() error = %v, want %v", err, ErrChecksumMismatch)
+		}
+	})
+	t.Run("TestGetFileResume-NotFound", func(t *testing.T) {
+		reset()
+		statusCode = http.StatusNotFound
+
+		filePath := filepath.Join(t.TempDir(), "file.bin")
+
+		response, err := directHttpRunner.GetFile(NewFileRequestOptions(server.URL, filePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(response.Body()); got != "not here" {
+			t.Errorf("response.Body() = %v, want %v", got, "not here")
+		}
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			t.Errorf("error response was written to %v", filePath)
+		}
+	})
+}
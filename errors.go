@@ -0,0 +1,101 @@
+package http_runner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Sentinels matched by *HttpError with errors.Is, ErrClientError and ErrServerError match
+// every status of their class.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrClientError  = errors.New("client error")
+	ErrServerError  = errors.New("server error")
+)
+
+// maxHttpErrorBody is how much of the response body a *HttpError keeps.
+const maxHttpErrorBody = 4096
+
+// HttpError is returned for responses with an error status, by the typed helpers and by requests
+// made with ErrorOnStatus. The response is returned next to it.
+type HttpError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte // the first 4 KiB of the response body
+	Truncated  bool   // whether Body is shorter than the response body
+	Attempts   int    // the attempts made, including the one that got this response
+}
+
+func newHttpError(response *resty.Response) *HttpError {
+	httpError := &HttpError{
+		StatusCode: response.StatusCode(),
+		Status:     response.Status(),
+		Header:     response.Header(),
+		Body:       response.Body(),
+	}
+
+	if response.Request != nil {
+		httpError.Method = response.Request.Method
+		httpError.Url = response.Request.URL
+
+		if attempts, ok := response.Request.Context().Value(attemptsKey{}).(*int); ok {
+			httpError.Attempts = *attempts
+		}
+	}
+
+	if len(httpError.Body) > maxHttpErrorBody {
+		httpError.Body = httpError.Body[:maxHttpErrorBody]
+		httpError.Truncated = true
+	}
+
+	return httpError
+}
+
+func (e *HttpError) Error() string {
+	status := e.Status
+	if len(status) <= 0 {
+		status = fmt.Sprintf("%d %v", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v %v: %v after %d attempts", e.Method, e.Url, status, e.Attempts)
+	}
+
+	return fmt.Sprintf("%v %v: %v", e.Method, e.Url, status)
+}
+
+func (e *HttpError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrClientError:
+		return e.StatusCode >= 400 && e.StatusCode < 500
+	case ErrServerError:
+		return e.StatusCode >= 500 && e.StatusCode < 600
+	}
+
+	return false
+}
+
+type attemptsKey struct{}
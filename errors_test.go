@@ -0,0 +1,106 @@
+package http_runner
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestErrorOnStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statusCode, _ := strconv.Atoi(r.URL.Query().Get("status"))
+
+		w.Header().Set("x-test", "true")
+		w.WriteHeader(statusCode)
+		_, _ = w.Write(bytes.Repeat([]byte("e"), 10000))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewHttpRunner(directDialer, WithErrorOnStatus(true), WithRetryCount(2), WithRetryPolicy(RetryPolicy{
+		MinBackoff:    time.Millisecond,
+		RetryOnStatus: []int{http.StatusServiceUnavailable},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status       int
+		wantIs       []error
+		wantIsNot    []error
+		wantAttempts int
+	}{
+		{status: http.StatusNotFound, wantIs: []error{ErrNotFound, ErrClientError}, wantIsNot: []error{ErrServerError, ErrRateLimited}, wantAttempts: 1},
+		{status: http.StatusTooManyRequests, wantIs: []error{ErrRateLimited, ErrClientError}, wantIsNot: []error{ErrNotFound}, wantAttempts: 1},
+		{status: http.StatusUnauthorized, wantIs: []error{ErrUnauthorized, ErrClientError}, wantIsNot: []error{ErrForbidden}, wantAttempts: 1},
+		{status: http.StatusServiceUnavailable, wantIs: []error{ErrServerError}, wantIsNot: []error{ErrClientError}, wantAttempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestErrorOnStatus-"+strconv.Itoa(tt.status), func(t *testing.T) {
+			response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + "?status=" + strconv.Itoa(tt.status)))
+			if response == nil || response.StatusCode() != tt.status {
+				t.Fatalf("runner.GetHtml() response = %v, want the %v response", response, tt.status)
+			}
+
+			var httpErr *HttpError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("runner.GetHtml() error = %v, want *HttpError", err)
+			}
+			for _, target := range tt.wantIs {
+				if !errors.Is(err, target) {
+					t.Errorf("errors.Is(%v, %v) = false, want true", err, target)
+				}
+			}
+			for _, target := range tt.wantIsNot {
+				if errors.Is(err, target) {
+					t.Errorf("errors.Is(%v, %v) = true, want false", err, target)
+				}
+			}
+
+			if httpErr.Method != http.MethodGet || httpErr.StatusCode != tt.status || httpErr.Header.Get("x-test") != "true" {
+				t.Errorf("HttpError = %+v, want the method, status and headers of the response", httpErr)
+			}
+			if len(httpErr.Body) != maxHttpErrorBody || !httpErr.Truncated {
+				t.Errorf("HttpError body is %v bytes, truncated %v, want %v bytes truncated", len(httpErr.Body), httpErr.Truncated, maxHttpErrorBody)
+			}
+			if httpErr.Attempts != tt.wantAttempts {
+				t.Errorf("HttpError.Attempts = %v, want %v", httpErr.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+	t.Run("TestErrorOnStatus-RequestOption", func(t *testing.T) {
+		htmlRequest := NewHtmlRequestOptions(server.URL + "?status=404")
+		htmlRequest.SetErrorOnStatusOption(false)
+
+		if _, err := runner.GetHtml(htmlRequest); err != nil {
+			t.Errorf("runner.GetHtml() error = %v, want nil", err)
+		}
+
+		defaultRunner, err := NewHttpRunner(directDialer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		htmlRequest = NewHtmlRequestOptions(server.URL + "?status=404")
+		if _, err := defaultRunner.GetHtml(htmlRequest); err != nil {
+			t.Errorf("defaultRunner.GetHtml() error = %v, want nil", err)
+		}
+
+		htmlRequest.SetErrorOnStatusOption(true)
+		if _, err := defaultRunner.GetHtml(htmlRequest); !errors.Is(err, ErrNotFound) {
+			t.Errorf("defaultRunner.GetHtml() error = %v, want %v", err, ErrNotFound)
+		}
+	})
+}
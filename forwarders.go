@@ -0,0 +1,213 @@
+package http_runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nadoo/glider/proxy"
+	"github.com/nadoo/glider/rule"
+)
+
+// ErrUnknownForwarder is returned for a request pinned to a forwarder WithForwarders does not have.
+var ErrUnknownForwarder = errors.New("unknown forwarder")
+
+// Forwarder is an upstream requests can be pinned to, see WithForwarders. Dialer is usually a
+// *rule.Forwarder made by rule.ForwarderFromURL, it has to be comparable. An empty Name is
+// taken from the address of Dialer.
+type Forwarder struct {
+	Name   string
+	Dialer proxy.TCPDialer
+}
+
+type forwarderKey struct{}
+
+// forwarderSelection is what a request asks of forwarderTransport.
+type forwarderSelection struct {
+	dialer    proxy.TCPDialer
+	stickyKey string
+}
+
+// forwarderTransport routes every request to the transport of its forwarder. Each forwarder
+// gets its own transport, so idle connections made through one forwarder are never reused
+// for a request pinned to another. Requests without a forwarder go to base, whose dials
+// follow the strategy of the rule.Proxy dialer, unless outcomes are recorded: the strategy
+// then picks the forwarder of every request.
+type forwarderTransport struct {
+	dialer     *rule.Proxy
+	forwarders []Forwarder
+	newStack   func(dial dialFunc) (*transportStack, error)
+	base       *transportStack
+	// feedback is nil when outcomes are not recorded
+	feedback *outcomeFeedback
+
+	mutex  sync.Mutex
+	stacks map[proxy.TCPDialer]*transportStack
+	// sticky maps sticky keys to their forwarder
+	sticky sync.Map
+	next   uint32
+}
+
+// forwarder finds the forwarder of WithForwarders requestOptions is pinned to, nil when it is not pinned.
+func (f *forwarderTransport) forwarder(requestOptions IBaseRequest) (proxy.TCPDialer, error) {
+	switch {
+	case requestOptions.IsForwarderSet():
+		for _, forwarder := range f.forwarders {
+			if forwarder.Name == requestOptions.Forwarder() {
+				return forwarder.Dialer, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w %q", ErrUnknownForwarder, requestOptions.Forwarder())
+	case requestOptions.IsForwarderIndexSet():
+		index := requestOptions.ForwarderIndex()
+		if index < 0 || index >= len(f.forwarders) {
+			return nil, fmt.Errorf("%w at index %d of %d", ErrUnknownForwarder, index, len(f.forwarders))
+		}
+
+		return f.forwarders[index].Dialer, nil
+	}
+
+	return nil, nil
+}
+
+func (f *forwarderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	selection, _ := req.Context().Value(forwarderKey{}).(forwarderSelection)
+
+	dialer := selection.dialer
+	sticky := dialer == nil && len(selection.stickyKey) > 0
+	switch {
+	case sticky:
+		dialer = f.stickyDialer(selection.stickyKey, canonicalAddr(req))
+	case dialer == nil && f.feedback != nil:
+		// outcomes are recorded on the forwarder of the request, which needs a pool of its own
+		dialer = f.dialer.NextDialer(canonicalAddr(req))
+	}
+
+	stack := f.base
+	if dialer != nil {
+		var err error
+		if stack, err = f.stack(dialer); err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+	}
+
+	resp, err := stack.roundTripper.RoundTrip(req)
+
+	verdict := Verdict{}
+	if f.feedback != nil {
+		verdict = f.feedback.classify(resp, err)
+		f.feedback.record(dialer, verdict, err)
+
+		// the idle connections of a forwarder left out of the rotation must not serve more requests
+		if forwarder, ok := dialer.(*rule.Forwarder); verdict.Outcome == OutcomeBanned || (ok && !forwarder.Enabled()) {
+			stack.closeIdleConnections()
+		}
+	}
+
+	// the next request with the key picks another forwarder
+	if sticky && ((err != nil && req.Context().Err() == nil) || verdict.Outcome == OutcomeFailed || verdict.Outcome == OutcomeBanned) {
+		f.sticky.CompareAndDelete(selection.stickyKey, dialer)
+	}
+
+	return resp, err
+}
+
+// stickyDialer returns the forwarder of key, picking one when the key has none: the next available
+// of WithForwarders, or the next of the rule.Proxy strategy for addr without them. Like glider,
+// all forwarders take turns when none is available.
+func (f *forwarderTransport) stickyDialer(key, addr string) proxy.TCPDialer {
+	if dialer, ok := f.sticky.Load(key); ok {
+		return dialer.(proxy.TCPDialer)
+	}
+
+	var dialer proxy.TCPDialer
+	if len(f.forwarders) > 0 {
+		available := make([]proxy.TCPDialer, 0, len(f.forwarders))
+		for _, forwarder := range f.forwarders {
+			if f.available(forwarder.Dialer) {
+				available = append(available, forwarder.Dialer)
+			}
+		}
+		if len(available) <= 0 {
+			for _, forwarder := range f.forwarders {
+				available = append(available, forwarder.Dialer)
+			}
+		}
+
+		dialer = available[(atomic.AddUint32(&f.next, 1)-1)%uint32(len(available))]
+	} else {
+		dialer = f.dialer.NextDialer(addr)
+	}
+
+	actual, _ := f.sticky.LoadOrStore(key, dialer)
+
+	return actual.(proxy.TCPDialer)
+}
+
+// available reports whether dialer may be given new sticky keys: a *rule.Forwarder has to be
+// enabled, and no forwarder may be banned.
+func (f *forwarderTransport) available(dialer proxy.TCPDialer) bool {
+	if forwarder, ok := dialer.(*rule.Forwarder); ok && !forwarder.Enabled() {
+		return false
+	}
+
+	return f.feedback == nil || !f.feedback.banned(dialer)
+}
+
+func (f *forwarderTransport) releaseSticky(key string) {
+	f.sticky.Delete(key)
+}
+
+// stack returns the transport of dialer, creating it on first use.
+func (f *forwarderTransport) stack(dialer proxy.TCPDialer) (*transportStack, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if stack, ok := f.stacks[dialer]; ok {
+		return stack, nil
+	}
+
+	stack, err := f.newStack(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialContext(ctx, dialer.Dial, network, addr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if f.stacks == nil {
+		f.stacks = map[proxy.TCPDialer]*transportStack{}
+	}
+	f.stacks[dialer] = stack
+
+	return stack, nil
+}
+
+func (f *forwarderTransport) closeIdleConnections() {
+	f.base.closeIdleConnections()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, stack := range f.stacks {
+		stack.closeIdleConnections()
+	}
+}
+
+// canonicalAddr returns the host and port of the url of req, the port defaulting to that of its scheme.
+func canonicalAddr(req *http.Request) string {
+	port := req.URL.Port()
+	if len(port) <= 0 {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
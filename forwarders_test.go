@@ -0,0 +1,150 @@
+package http_runner
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+// testForwarder dials directly, counting its dials, and fails them while failing is set.
+type testForwarder struct {
+	addr    string
+	dials   int32
+	failing int32
+}
+
+func (f *testForwarder) Addr() string {
+	return f.addr
+}
+
+func (f *testForwarder) Dial(network, addr string) (net.Conn, error) {
+	atomic.AddInt32(&f.dials, 1)
+	if atomic.LoadInt32(&f.failing) > 0 {
+		return nil, errors.New("forwarder down")
+	}
+
+	return net.Dial(network, addr)
+}
+
+func TestForwarders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRunner := func(t *testing.T, forwarders ...*testForwarder) IHttpRunner {
+		options := []Option{WithRetryCount(1), WithTransportOptions(TransportOptions{DisableKeepAlives: true})}
+		if len(forwarders) > 0 {
+			named := make([]Forwarder, 0, len(forwarders))
+			for _, forwarder := range forwarders {
+				named = append(named, Forwarder{Dialer: forwarder})
+			}
+			options = append(options, WithForwarders(named...))
+		}
+
+		runner, err := NewProxyHttpRunner(directDialer, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return runner
+	}
+
+	get := func(runner IHttpRunner, configure func(requestOptions IHtmlRequestOptions)) error {
+		requestOptions := NewHtmlRequestOptions(server.URL)
+		configure(requestOptions)
+
+		_, err := runner.GetHtml(requestOptions)
+		return err
+	}
+
+	dials := func(forwarders ...*testForwarder) []int32 {
+		result := make([]int32, 0, len(forwarders))
+		for _, forwarder := range forwarders {
+			result = append(result, atomic.LoadInt32(&forwarder.dials))
+		}
+
+		return result
+	}
+
+	t.Run("TestForwarders-Pin", func(t *testing.T) {
+		a, b := &testForwarder{addr: "a"}, &testForwarder{addr: "b"}
+		runner := newRunner(t, a, b)
+
+		for i := 0; i < 3; i++ {
+			if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetForwarder("b") }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetForwarderIndex(0) }); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := dials(a, b); got[0] != 1 || got[1] != 3 {
+			t.Errorf("dials = %v, want [1 3]", got)
+		}
+
+		if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetForwarder("c") }); !errors.Is(err, ErrUnknownForwarder) {
+			t.Errorf("error = %v, want %v", err, ErrUnknownForwarder)
+		}
+		if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetForwarderIndex(2) }); !errors.Is(err, ErrUnknownForwarder) {
+			t.Errorf("error = %v, want %v", err, ErrUnknownForwarder)
+		}
+	})
+	t.Run("TestForwarders-Sticky", func(t *testing.T) {
+		a, b, c := &testForwarder{addr: "a"}, &testForwarder{addr: "b"}, &testForwarder{addr: "c"}
+		runner := newRunner(t, a, b, c)
+
+		for i := 0; i < 3; i++ {
+			for _, key := range []string{"first", "second"} {
+				if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetStickyKey(key) }); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if got := dials(a, b, c); got[0] != 3 || got[1] != 3 || got[2] != 0 {
+			t.Errorf("dials = %v, want [3 3 0]", got)
+		}
+
+		// the failed attempt unsticks the key, the retry moves to the next forwarder
+		atomic.StoreInt32(&a.failing, 1)
+		for i := 0; i < 2; i++ {
+			if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetStickyKey("first") }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := dials(a, b, c); got[0] != 4 || got[1] != 3 || got[2] != 2 {
+			t.Errorf("dials = %v, want [4 3 2]", got)
+		}
+
+		// a released key picks the next forwarder, the others keep theirs
+		atomic.StoreInt32(&a.failing, 0)
+		runner.ReleaseSticky("first")
+		for _, key := range []string{"first", "second"} {
+			if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetStickyKey(key) }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := dials(a, b, c); got[0] != 5 || got[1] != 4 || got[2] != 2 {
+			t.Errorf("dials = %v, want [5 4 2]", got)
+		}
+	})
+	t.Run("TestForwarders-StickyWithoutForwarders", func(t *testing.T) {
+		runner := newRunner(t)
+
+		if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetStickyKey("first") }); err != nil {
+			t.Fatal(err)
+		}
+		if err := get(runner, func(requestOptions IHtmlRequestOptions) { requestOptions.SetForwarderIndex(0) }); !errors.Is(err, ErrUnknownForwarder) {
+			t.Errorf("error = %v, want %v", err, ErrUnknownForwarder)
+		}
+	})
+}
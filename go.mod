@@ -1,18 +1,21 @@
 module github.com/Tanreon/go-http-runner
 
-go 1.18
+go 1.24
 
 require (
 	github.com/Tanreon/go-network-runner v0.0.0-20231205102417-d90c436f1736
+	github.com/andybalholm/brotli v1.0.6
 	github.com/go-resty/resty/v2 v2.11.0
+	github.com/klauspost/compress v1.18.0
 	github.com/nadoo/glider v0.16.3
+	github.com/refraction-networking/utls v1.8.2
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/net v0.38.0
 )
 
 require (
 	github.com/aead/chacha20 v0.0.0-20180709150244-8b13a72661da // indirect
 	github.com/nadoo/conflag v0.3.1 // indirect
-	golang.org/x/crypto v0.18.0 // indirect
-	golang.org/x/net v0.20.0 // indirect
-	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
 )
@@ -0,0 +1,149 @@
+package http_runner
+
+import (
+	"sort"
+	"strings"
+)
+
+// Header is a single header field, its key is sent as it is written.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Headers is an ordered list of header fields, a key may appear more than once. Keys are
+// compared case-insensitively. The methods return a new list and never change the receiver.
+type Headers []Header
+
+// NewHeaders builds headers from key and value pairs, in their order.
+func NewHeaders(keyValues ...string) Headers {
+	headers := make(Headers, 0, len(keyValues)/2)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		headers = append(headers, Header{Key: keyValues[i], Value: keyValues[i+1]})
+	}
+
+	return headers
+}
+
+// HeadersFromMap builds headers from a map, ordered by key.
+func HeadersFromMap(headers map[string]string) Headers {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(Headers, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, Header{Key: key, Value: headers[key]})
+	}
+
+	return result
+}
+
+// Get returns the first value of key.
+func (h Headers) Get(key string) string {
+	for _, header := range h {
+		if strings.EqualFold(header.Key, key) {
+			return header.Value
+		}
+	}
+
+	return ""
+}
+
+// Values returns every value of key, in order.
+func (h Headers) Values(key string) []string {
+	var values []string
+	for _, header := range h {
+		if strings.EqualFold(header.Key, key) {
+			values = append(values, header.Value)
+		}
+	}
+
+	return values
+}
+
+// Add appends a value of key.
+func (h Headers) Add(key, value string) Headers {
+	return append(h.Clone(), Header{Key: key, Value: value})
+}
+
+// Set replaces every value of key with value, at the position of the first one.
+func (h Headers) Set(key, value string) Headers {
+	return h.merge(Headers{{Key: key, Value: value}})
+}
+
+// Del removes every value of key.
+func (h Headers) Del(key string) Headers {
+	result := make(Headers, 0, len(h))
+	for _, header := range h {
+		if !strings.EqualFold(header.Key, key) {
+			result = append(result, header)
+		}
+	}
+
+	return result
+}
+
+func (h Headers) Clone() Headers {
+	if h == nil {
+		return nil
+	}
+
+	return append(make(Headers, 0, len(h)), h...)
+}
+
+// merge replaces the values of the keys of other at the position of their first value,
+// keys h does not have are appended in the order of other.
+func (h Headers) merge(other Headers) Headers {
+	result := h.Clone()
+
+	for _, key := range other.keys() {
+		values := Headers{}
+		for _, header := range other {
+			if strings.EqualFold(header.Key, key) {
+				values = append(values, header)
+			}
+		}
+
+		position := -1
+		merged := make(Headers, 0, len(result)+len(values))
+		for _, header := range result {
+			if !strings.EqualFold(header.Key, key) {
+				merged = append(merged, header)
+			} else if position < 0 {
+				position = len(merged)
+			}
+		}
+
+		if position < 0 {
+			position = len(merged)
+		}
+
+		result = append(merged[:position], append(values, merged[position:]...)...)
+	}
+
+	return result
+}
+
+// keys returns the distinct keys in the order of their first value.
+func (h Headers) keys() []string {
+	var keys []string
+
+	for _, header := range h {
+		found := false
+		for _, key := range keys {
+			if strings.EqualFold(key, header.Key) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			keys = append(keys, header.Key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,195 @@
+package http_runner
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestHeaders(t *testing.T) {
+	headers := NewHeaders("Accept", "a", "X-Test", "1", "accept", "b")
+
+	if got := headers.Values("ACCEPT"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Values() = %v, want %v", got, []string{"a", "b"})
+	}
+
+	set := headers.Set("accept", "c")
+	if want := NewHeaders("accept", "c", "X-Test", "1"); !reflect.DeepEqual(set, want) {
+		t.Errorf("Set() = %v, want %v", set, want)
+	}
+	if got := headers.Get("accept"); got != "a" {
+		t.Errorf("Set() changed the receiver, Get() = %v, want %v", got, "a")
+	}
+
+	merged := headers.merge(NewHeaders("X-New", "n", "x-test", "2", "x-test", "3"))
+	if want := NewHeaders("Accept", "a", "x-test", "2", "x-test", "3", "accept", "b", "X-New", "n"); !reflect.DeepEqual(merged, want) {
+		t.Errorf("merge() = %v, want %v", merged, want)
+	}
+
+	if got := headers.Del("accept").Add("Link", "<a>"); !reflect.DeepEqual(got, NewHeaders("X-Test", "1", "Link", "<a>")) {
+		t.Errorf("Del().Add() = %v", got)
+	}
+}
+
+// headServer answers every request with the head of the request as it was received.
+func headServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+
+				var head []string
+				contentLength := 0
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					if len(line) <= 0 {
+						break
+					}
+
+					head = append(head, line)
+					if strings.HasPrefix(strings.ToLower(line), "content-length: ") {
+						contentLength, _ = strconv.Atoi(line[len("content-length: "):])
+					}
+				}
+
+				body := make([]byte, contentLength)
+				if _, err := io.ReadFull(reader, body); err != nil {
+					return
+				}
+				head = append(head, "", string(body))
+
+				response := strings.Join(head, "\n")
+				_, _ = fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: %d\r\n\r\n%s", len(response), response)
+			}(conn)
+		}
+	}()
+
+	return "http://" + listener.Addr().String()
+}
+
+func TestOrderedHeaders(t *testing.T) {
+	serverUrl := headServer(t)
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewHttpRunner(directDialer, WithOrderedHeaders(NewHeaders(
+		"Accept", "text/html",
+		"X-B", "1",
+		"Link", "<a>",
+		"Link", "<b>",
+		"user-agent", "test-agent",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestOrderedHeaders-Get", func(t *testing.T) {
+		htmlRequest := NewHtmlRequestOptions(serverUrl + "/path?q=1")
+		htmlRequest.SetHeaders(map[string]string{"x-b": "2"})
+		htmlRequest.SetOrderedHeaders(NewHeaders("X-A", "request"))
+
+		response, err := runner.GetHtml(htmlRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"GET /path?q=1 HTTP/1.1",
+			"Host: " + strings.TrimPrefix(serverUrl, "http://"),
+			"Accept: text/html",
+			"x-b: 2",
+			"Link: <a>",
+			"Link: <b>",
+			"user-agent: test-agent",
+			"X-A: request",
+			"",
+			"",
+		}
+		if got := strings.Split(string(response.Body()), "\n"); !reflect.DeepEqual(got, want) {
+			t.Errorf("request head =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+	t.Run("TestOrderedHeaders-Post", func(t *testing.T) {
+		jsonRequest := NewJsonRequestOptions(serverUrl)
+		jsonRequest.SetValue([]byte(`{"test":true}`))
+		jsonRequest.SetOrderedHeaders(NewHeaders("Content-Type", "application/json", "Accept", "application/json"))
+
+		response, err := runner.PostJson(jsonRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"POST / HTTP/1.1",
+			"Host: " + strings.TrimPrefix(serverUrl, "http://"),
+			"Accept: application/json",
+			"X-B: 1",
+			"Link: <a>",
+			"Link: <b>",
+			"user-agent: test-agent",
+			"Content-Type: application/json",
+			"Content-Length: 13",
+			"",
+			`{"test":true}`,
+		}
+		if got := strings.Split(string(response.Body()), "\n"); !reflect.DeepEqual(got, want) {
+			t.Errorf("request =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+	t.Run("TestOrderedHeaders-Https", func(t *testing.T) {
+		tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("x-proto", r.Proto)
+		}))
+		defer tlsServer.Close()
+
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(tlsServer.Certificate())
+
+		tlsRunner, err := NewHttpRunner(directDialer, WithTLSConfig(&tls.Config{RootCAs: rootCAs}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		htmlRequest := NewHtmlRequestOptions(tlsServer.URL)
+		htmlRequest.SetOrderedHeaders(NewHeaders("Accept", "*/*"))
+
+		response, err := tlsRunner.GetHtml(htmlRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.Header().Get("x-proto"); got != "HTTP/1.1" {
+			t.Errorf("protocol = %v, want %v", got, "HTTP/1.1")
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package http_runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nadoo/glider/proxy"
+	"github.com/nadoo/glider/rule"
+)
+
+// Outcome is how well a forwarder did at a request.
+type Outcome int
+
+const (
+	// OutcomeUnknown leaves the decision to the next classifier, or records nothing.
+	OutcomeUnknown Outcome = iota
+	// OutcomeHealthy resets the failures of an enabled forwarder, a disabled one stays disabled.
+	OutcomeHealthy
+	// OutcomeFailed counts a failure, a *rule.Forwarder is disabled at the max failures of its strategy.
+	OutcomeFailed
+	// OutcomeBanned disables the forwarder for a cooldown.
+	OutcomeBanned
+)
+
+// DefaultBanCooldown is how long a banned forwarder stays disabled when its verdict sets no cooldown.
+var DefaultBanCooldown = time.Minute * 10
+
+// maxClassifiedBody is how much of a response body classifiers can look at.
+const maxClassifiedBody = 64 * 1024
+
+// Verdict is the judgement of a classifier, Cooldown only applies to OutcomeBanned.
+type Verdict struct {
+	Outcome  Outcome
+	Cooldown time.Duration
+}
+
+// OutcomeClassifier judges the forwarder of a request from its response, or from err when
+// there is none. body returns the first 64 KiB of the response body, reading them on the first
+// call; the response is returned only after that, so classifiers call it only when needed.
+type OutcomeClassifier func(response *http.Response, body func() []byte, err error) Verdict
+
+// OutcomeRecorder is implemented by forwarders other than *rule.Forwarder that want the verdicts on them.
+type OutcomeRecorder interface {
+	RecordOutcome(verdict Verdict)
+}
+
+// ConnectErrorClassifier fails the forwarder of a request whose connection could not be dialed,
+// the handshake with the proxy included. Canceled and timed out requests, and errors after the
+// dial, such as a target resetting the connection, say nothing about the forwarder.
+func ConnectErrorClassifier(response *http.Response, body func() []byte, err error) Verdict {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || !isDialError(err) {
+		return Verdict{}
+	}
+
+	return Verdict{Outcome: OutcomeFailed}
+}
+
+// ProxyAuthClassifier fails the forwarder of a request answered with 407 Proxy Authentication Required.
+func ProxyAuthClassifier(response *http.Response, body func() []byte, err error) Verdict {
+	if response != nil && response.StatusCode == http.StatusProxyAuthRequired {
+		return Verdict{Outcome: OutcomeFailed}
+	}
+
+	return Verdict{}
+}
+
+// SuccessClassifier marks the forwarder of a request answered below 500 as healthy, the
+// forwarder did its job whatever the status. 5xx statuses are left unknown.
+func SuccessClassifier(response *http.Response, body func() []byte, err error) Verdict {
+	if response != nil && response.StatusCode < http.StatusInternalServerError {
+		return Verdict{Outcome: OutcomeHealthy}
+	}
+
+	return Verdict{}
+}
+
+// DefaultOutcomeClassifiers judge connect errors, 407 responses and answered requests, see WithOutcomeClassifiers.
+var DefaultOutcomeClassifiers = []OutcomeClassifier{ConnectErrorClassifier, ProxyAuthClassifier, SuccessClassifier}
+
+// BanRule detects responses telling the forwarder is banned, such as a 403 or a captcha page.
+// A response matches when its status is one of StatusCodes and its body matches BodyPattern,
+// an empty StatusCodes or a nil BodyPattern matching anything. Bodies are read only for
+// responses matching StatusCodes.
+type BanRule struct {
+	StatusCodes []int
+	BodyPattern *regexp.Regexp
+	// Cooldown is how long the forwarder stays disabled, DefaultBanCooldown when zero.
+	Cooldown time.Duration
+}
+
+// Classifier returns the classifier banning the forwarders of matching responses.
+func (b BanRule) Classifier() OutcomeClassifier {
+	return func(response *http.Response, body func() []byte, err error) Verdict {
+		if response == nil || (len(b.StatusCodes) <= 0 && b.BodyPattern == nil) {
+			return Verdict{}
+		}
+
+		if len(b.StatusCodes) > 0 {
+			matched := false
+			for _, statusCode := range b.StatusCodes {
+				if response.StatusCode == statusCode {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return Verdict{}
+			}
+		}
+
+		if b.BodyPattern != nil && !b.BodyPattern.Match(body()) {
+			return Verdict{}
+		}
+
+		return Verdict{Outcome: OutcomeBanned, Cooldown: b.Cooldown}
+	}
+}
+
+// outcomeFeedback classifies the outcomes of requests and records them on their forwarders.
+type outcomeFeedback struct {
+	classifiers []OutcomeClassifier
+	// bans holds the *ban of banned forwarders
+	bans sync.Map
+}
+
+// ban is the cooldown of a banned forwarder.
+type ban struct {
+	until time.Time
+	// enable tells whether the forwarder was enabled before it was banned, only then is it
+	// enabled again after the cooldown
+	enable bool
+}
+
+// banned reports whether dialer is in the cooldown of a ban.
+func (o *outcomeFeedback) banned(dialer proxy.TCPDialer) bool {
+	entry, ok := o.bans.Load(dialer)
+
+	return ok && time.Now().Before(entry.(*ban).until)
+}
+
+// classify returns the first verdict that is not OutcomeUnknown. When a classifier reads the
+// body, response gets a body replaying what was read.
+func (o *outcomeFeedback) classify(response *http.Response, err error) Verdict {
+	var (
+		read bool
+		head []byte
+	)
+	body := func() []byte {
+		if read || response == nil || response.Body == nil {
+			return head
+		}
+		read = true
+
+		head, _ = io.ReadAll(io.LimitReader(response.Body, maxClassifiedBody))
+		response.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(head), response.Body), body: response.Body}
+
+		return head
+	}
+
+	for _, classifier := range o.classifiers {
+		if verdict := classifier(response, body, err); verdict.Outcome != OutcomeUnknown {
+			return verdict
+		}
+	}
+
+	return Verdict{}
+}
+
+// record applies verdict to dialer. A *rule.Forwarder counts the failures of its dials itself.
+// A healthy verdict never enables a forwarder: one disabled by its failures, a glider check or
+// its owner stays disabled until enabled again by them, a banned one until its cooldown ends.
+func (o *outcomeFeedback) record(dialer proxy.TCPDialer, verdict Verdict, err error) {
+	if verdict.Outcome == OutcomeUnknown {
+		return
+	}
+
+	forwarder, ok := dialer.(*rule.Forwarder)
+	if !ok {
+		if recorder, ok := dialer.(OutcomeRecorder); ok {
+			recorder.RecordOutcome(verdict)
+		}
+
+		return
+	}
+
+	switch verdict.Outcome {
+	case OutcomeHealthy:
+		// Enable is the only way to reset the failures, it is harmless on an enabled forwarder
+		if forwarder.Enabled() && !o.banned(forwarder) {
+			forwarder.Enable()
+		}
+	case OutcomeFailed:
+		if !isDialError(err) {
+			forwarder.IncFailures()
+		}
+	case OutcomeBanned:
+		cooldown := verdict.Cooldown
+		if cooldown <= 0 {
+			cooldown = DefaultBanCooldown
+		}
+
+		entry := &ban{until: time.Now().Add(cooldown), enable: forwarder.Enabled()}
+		// a ban extending another keeps what the forwarder was before the first
+		if previous, ok := o.bans.Load(forwarder); ok {
+			entry.enable = entry.enable || previous.(*ban).enable
+		}
+		o.bans.Store(forwarder, entry)
+		forwarder.Disable()
+
+		time.AfterFunc(cooldown, func() {
+			// a later ban extends the cooldown
+			if o.bans.CompareAndDelete(forwarder, entry) && entry.enable {
+				forwarder.Enable()
+			}
+		})
+	}
+}
+
+// replayBody returns the bytes read by classifiers before the rest of body.
+type replayBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (r *replayBody) Close() error {
+	return r.body.Close()
+}
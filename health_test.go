@@ -0,0 +1,375 @@
+package http_runner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/nadoo/glider/proxy/http"
+	"github.com/nadoo/glider/rule"
+)
+
+// connectProxy is a local HTTP CONNECT proxy counting its tunnels. A captcha proxy answers
+// the requests through the tunnel itself with a captcha page, as a banned exit would, keeping
+// the tunnel alive. A leaking proxy forwards the first request adding Via and X-Forwarded-For.
+// localIp is the address the proxy connects to targets from.
+type connectProxy struct {
+	listener net.Listener
+	tunnels  int32
+	captcha  bool
+	leak     bool
+	localIp  string
+}
+
+func newConnectProxy(t *testing.T, captcha bool) *connectProxy {
+	return startConnectProxy(t, &connectProxy{captcha: captcha})
+}
+
+func startConnectProxy(t *testing.T, p *connectProxy) *connectProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.listener = listener
+	go p.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return p
+}
+
+func (p *connectProxy) url() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+func (p *connectProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go p.handle(conn)
+	}
+}
+
+func (p *connectProxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil || request.Method != http.MethodConnect {
+		return
+	}
+	atomic.AddInt32(&p.tunnels, 1)
+
+	if p.captcha {
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		for {
+			if _, err := http.ReadRequest(reader); err != nil {
+				return
+			}
+
+			page := "<html>please solve the captcha</html>"
+			fmt.Fprintf(conn, "HTTP/1.1 403 Forbidden\r\nContent-Length: %d\r\n\r\n%s", len(page), page)
+		}
+	}
+
+	dialer := net.Dialer{}
+	if len(p.localIp) > 0 {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(p.localIp)}
+	}
+
+	target, err := dialer.Dial("tcp", request.Host)
+	if err != nil {
+		fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer target.Close()
+
+	fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	if p.leak {
+		tunneled, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+
+		clientIp, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		tunneled.Header.Set("Via", "1.1 test-proxy")
+		tunneled.Header.Set("X-Forwarded-For", clientIp)
+		tunneled.Header.Set("Connection", "close")
+		if err := tunneled.Write(target); err != nil {
+			return
+		}
+
+		io.Copy(conn, target)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(target, reader)
+		target.(*net.TCPConn).CloseWrite()
+	}()
+	io.Copy(conn, target)
+	wg.Wait()
+}
+
+// recordingForwarder keeps the verdicts recorded on it.
+type recordingForwarder struct {
+	testForwarder
+	mutex    sync.Mutex
+	outcomes []Outcome
+}
+
+func (r *recordingForwarder) RecordOutcome(verdict Verdict) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.outcomes = append(r.outcomes, verdict.Outcome)
+}
+
+func TestOutcomeFeedback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	t.Run("TestOutcomeFeedback-BanRule", func(t *testing.T) {
+		good, banned := newConnectProxy(t, false), newConnectProxy(t, true)
+		// glider makes its forwarders disabled, its check enables them
+		dialer := rule.NewProxy([]string{good.url(), banned.url()}, &rule.Strategy{
+			Strategy: "rr", MaxFailures: 3, DialTimeout: 3,
+			Check: "tcp://" + server.Listener.Addr().String(), CheckInterval: 3600, CheckTimeout: 3,
+		}, nil)
+		dialer.Check()
+		waitEnabled(t, dialer)
+		atomic.StoreInt32(&banned.tunnels, 0)
+
+		runner, err := NewProxyHttpRunner(dialer,
+			WithRetryCount(0),
+			WithOutcomeClassifiers(DefaultOutcomeClassifiers...),
+			WithBanRules(BanRule{StatusCodes: []int{http.StatusForbidden}, BodyPattern: regexp.MustCompile("captcha"), Cooldown: time.Millisecond * 300}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send := func(count int) (captchas int) {
+			for i := 0; i < count; i++ {
+				response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if response.StatusCode() == http.StatusForbidden {
+					captchas++
+					// the body read by the ban rule is still returned whole
+					if !strings.Contains(response.String(), "please solve the captcha") {
+						t.Errorf("body = %q, want the captcha page", response.String())
+					}
+				}
+			}
+
+			return captchas
+		}
+
+		if captchas := send(6); captchas != 1 {
+			t.Errorf("got %v captchas, want 1 before the forwarder is banned", captchas)
+		}
+		if tunnels := atomic.LoadInt32(&banned.tunnels); tunnels != 1 {
+			t.Errorf("banned forwarder got %v tunnels, want 1", tunnels)
+		}
+
+		// after the cooldown the forwarder is back in the rotation
+		time.Sleep(time.Millisecond * 500)
+		if captchas := send(2); captchas != 1 {
+			t.Errorf("got %v captchas after the cooldown, want 1", captchas)
+		}
+	})
+	t.Run("TestOutcomeFeedback-Recorder", func(t *testing.T) {
+		forwarder := &recordingForwarder{testForwarder: testForwarder{addr: "recording"}}
+		runner, err := NewProxyHttpRunner(rule.NewProxy(nil, &rule.Strategy{Strategy: "rr"}, nil),
+			WithRetryCount(0),
+			WithForwarders(Forwarder{Dialer: forwarder}),
+			WithOutcomeClassifiers(DefaultOutcomeClassifiers...),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requestOptions := NewHtmlRequestOptions(server.URL)
+		requestOptions.SetForwarderIndex(0)
+
+		if _, err := runner.GetHtml(requestOptions); err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreInt32(&forwarder.failing, 1)
+		runner.Close()
+		if _, err := runner.GetHtml(requestOptions); err == nil {
+			t.Fatal("error = nil through a failing forwarder")
+		}
+
+		forwarder.mutex.Lock()
+		defer forwarder.mutex.Unlock()
+
+		if want := []Outcome{OutcomeHealthy, OutcomeFailed}; fmt.Sprint(forwarder.outcomes) != fmt.Sprint(want) {
+			t.Errorf("outcomes = %v, want %v", forwarder.outcomes, want)
+		}
+	})
+	t.Run("TestOutcomeFeedback-StickyAfterBan", func(t *testing.T) {
+		good, banned := newConnectProxy(t, false), newConnectProxy(t, true)
+
+		forwarders := make([]Forwarder, 0, 2)
+		for _, proxyUrl := range []string{banned.url(), good.url()} {
+			forwarder, err := rule.ForwarderFromURL(proxyUrl, "", time.Second*3, time.Second*3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forwarder.Enable()
+
+			forwarders = append(forwarders, Forwarder{Dialer: forwarder})
+		}
+
+		runner, err := NewProxyHttpRunner(rule.NewProxy(nil, &rule.Strategy{Strategy: "rr"}, nil),
+			WithRetryCount(0),
+			WithForwarders(forwarders...),
+			WithOutcomeClassifiers(DefaultOutcomeClassifiers...),
+			WithBanRules(BanRule{StatusCodes: []int{http.StatusForbidden}, Cooldown: time.Hour}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		captchas := 0
+		for i := 0; i < 6; i++ {
+			requestOptions := NewHtmlRequestOptions(server.URL)
+			requestOptions.SetStickyKey(fmt.Sprint("key-", i))
+
+			response, err := runner.GetHtml(requestOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode() == http.StatusForbidden {
+				captchas++
+			}
+		}
+
+		// the first key gets the banned forwarder, the next ones are given the good one only
+		if captchas != 1 {
+			t.Errorf("got %v captchas, want 1 before the forwarder is banned", captchas)
+		}
+		if tunnels := atomic.LoadInt32(&good.tunnels); tunnels < 1 {
+			t.Errorf("good forwarder got %v tunnels, want at least 1", tunnels)
+		}
+	})
+	t.Run("TestOutcomeFeedback-Enable", func(t *testing.T) {
+		newForwarder := func(t *testing.T, enabled bool) *rule.Forwarder {
+			forwarder, err := rule.ForwarderFromURL("http://127.0.0.1:1", "", time.Second, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forwarder.SetMaxFailures(2)
+			if enabled {
+				forwarder.Enable()
+			}
+
+			return forwarder
+		}
+
+		feedback := &outcomeFeedback{}
+		failure := errors.New("connection reset")
+
+		forwarder := newForwarder(t, true)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeFailed}, failure)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeHealthy}, nil)
+		if !forwarder.Enabled() || forwarder.Failures() != 0 {
+			t.Errorf("enabled = %v with %v failures, want the failures of the enabled forwarder reset", forwarder.Enabled(), forwarder.Failures())
+		}
+
+		feedback.record(forwarder, Verdict{Outcome: OutcomeFailed}, failure)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeFailed}, failure)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeHealthy}, nil)
+		if forwarder.Enabled() {
+			t.Error("forwarder disabled by its failures enabled by a healthy request")
+		}
+
+		// disabled by glider or by hand
+		forwarder = newForwarder(t, false)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeHealthy}, nil)
+		if forwarder.Enabled() {
+			t.Error("disabled forwarder enabled by a healthy request")
+		}
+
+		forwarder = newForwarder(t, true)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeBanned, Cooldown: time.Millisecond * 50}, nil)
+		feedback.record(forwarder, Verdict{Outcome: OutcomeHealthy}, nil)
+		if forwarder.Enabled() {
+			t.Error("forwarder enabled during its ban cooldown")
+		}
+		disabled := newForwarder(t, false)
+		feedback.record(disabled, Verdict{Outcome: OutcomeBanned, Cooldown: time.Millisecond * 50}, nil)
+
+		time.Sleep(time.Millisecond * 200)
+		if !forwarder.Enabled() {
+			t.Error("banned forwarder still disabled after its cooldown")
+		}
+		if disabled.Enabled() {
+			t.Error("forwarder disabled before its ban enabled after the cooldown")
+		}
+	})
+}
+
+// waitEnabled waits for the glider check to enable every forwarder of dialer.
+func waitEnabled(t *testing.T, dialer *rule.Proxy) {
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond * 10) {
+		enabled := 0
+		for i := 0; i < 4; i++ {
+			if forwarder, ok := dialer.NextDialer("127.0.0.1:80").(*rule.Forwarder); ok && forwarder.Enabled() {
+				enabled++
+			}
+		}
+		if enabled == 4 {
+			return
+		}
+	}
+
+	t.Fatal("forwarders not enabled by the check")
+}
+
+func TestConnectErrorClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Outcome
+	}{
+		{"NoError", nil, OutcomeUnknown},
+		{"Dial", fmt.Errorf("request: %w", &dialError{err: errors.New("proxy refused the connection")}), OutcomeFailed},
+		{"DialOp", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, OutcomeFailed},
+		{"Target", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, OutcomeUnknown},
+		{"Canceled", fmt.Errorf("request: %w", context.Canceled), OutcomeUnknown},
+		{"DeadlineExceeded", fmt.Errorf("request: %w", context.DeadlineExceeded), OutcomeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestConnectErrorClassifier-"+tt.name, func(t *testing.T) {
+			if got := ConnectErrorClassifier(nil, func() []byte { return nil }, tt.err); got.Outcome != tt.want {
+				t.Errorf("ConnectErrorClassifier(%v) = %v, want %v", tt.err, got.Outcome, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,15 @@
 package http_runner
 
 import (
-	"bufio"
-	"io"
+	"context"
+	"net"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/nadoo/glider/proxy"
 )
 
 var DefaultHeaders = map[string]string{
@@ -25,7 +24,39 @@ type IHttpRunner interface {
 	GetFile(requestOptions IFileRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
 	PostJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
 	PutJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	PatchJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	DeleteJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
 	PostForm(requestOptions IFormRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	Head(requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error)
+	Options(requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error)
+	// Do sends a request with any method; the body is taken from the options type
+	// (JSON value, form values and files, file output) just like the dedicated methods.
+	Do(method string, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error)
+
+	GetJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	GetHtmlWithContext(ctx context.Context, requestOptions IHtmlRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	GetFileWithContext(ctx context.Context, requestOptions IFileRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	PostJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	PutJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	PatchJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	DeleteJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	PostFormWithContext(ctx context.Context, requestOptions IFormRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+	HeadWithContext(ctx context.Context, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error)
+	OptionsWithContext(ctx context.Context, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error)
+	DoWithContext(ctx context.Context, method string, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error)
+
+	// WithCookieJar returns a session sharing this runner's client, whose requests store
+	// the cookies set by responses in jar instead of the jar of the runner and send them back
+	// automatically. A nil jar keeps no cookies.
+	WithCookieJar(jar http.CookieJar) IHttpRunner
+	// WithRedirectPolicy returns a runner sharing this runner's client that follows redirects
+	// according to policy, for every method, when a request does not set its own FollowRedirectOption.
+	WithRedirectPolicy(policy RedirectPolicy) IHttpRunner
+	// ReleaseSticky forgets the forwarder of a sticky key, the next request with the key picks
+	// another one. Keys are kept until released or until a request through their forwarder fails.
+	ReleaseSticky(key string)
+	// Close closes the idle connections of the runner and its sessions.
+	Close() error
 }
 
 type IBaseRequest interface {
@@ -35,10 +66,22 @@ type IBaseRequest interface {
 	SetHeaders(headers map[string]string)
 	Headers() map[string]string
 
+	IsOrderedHeadersSet() bool
+	SetOrderedHeaders(headers Headers)
+	OrderedHeaders() Headers
+
+	IsQueryParamsSet() bool
+	SetQueryParams(params url.Values)
+	QueryParams() url.Values
+
 	IsRetryOptionSet() bool
 	SetRetryOption(count int)
 	RetryOption() int
 
+	IsRetryPolicyOptionSet() bool
+	SetRetryPolicyOption(policy RetryPolicy)
+	RetryPolicyOption() RetryPolicy
+
 	IsTimeoutOptionSet() bool
 	SetTimeoutOption(timeout time.Duration)
 	TimeoutOption() time.Duration
@@ -46,178 +89,237 @@ type IBaseRequest interface {
 	IsFollowRedirectOptionSet() bool
 	SetFollowRedirectOption(follow bool)
 	FollowRedirectOption() bool
-}
 
-//
+	IsErrorOnStatusOptionSet() bool
+	SetErrorOnStatusOption(errorOnStatus bool)
+	ErrorOnStatusOption() bool
 
-type IJsonRequestOptions interface {
-	IBaseRequest
+	IsForwarderSet() bool
+	SetForwarder(name string)
+	Forwarder() string
 
-	IsValueSet() bool
-	SetValue(bytes []byte)
-	Value() []byte
+	IsForwarderIndexSet() bool
+	SetForwarderIndex(index int)
+	ForwarderIndex() int
+
+	IsStickyKeySet() bool
+	SetStickyKey(key string)
+	StickyKey() string
 }
 
-type JsonRequestOptions struct {
+// baseRequestOptions implements IBaseRequest for every request options type.
+type baseRequestOptions struct {
 	url            string
-	value          *[]byte
 	headers        *map[string]string
+	orderedHeaders *Headers
+	queryParams    *url.Values
 	retryCount     *int
+	retryPolicy    *RetryPolicy
 	timeout        *time.Duration
 	followRedirect *bool
+	errorOnStatus  *bool
+	forwarder      *string
+	forwarderIndex *int
+	stickyKey      *string
 }
 
-func (j *JsonRequestOptions) Url() string {
-	return j.url
+func (b *baseRequestOptions) Url() string {
+	return b.url
 }
 
-func (j *JsonRequestOptions) IsHeadersSet() bool {
-	return j.headers != nil
+func (b *baseRequestOptions) IsHeadersSet() bool {
+	return b.headers != nil
 }
-func (j *JsonRequestOptions) SetHeaders(headers map[string]string) {
-	j.headers = &headers
+func (b *baseRequestOptions) SetHeaders(headers map[string]string) {
+	b.headers = &headers
 }
-func (j *JsonRequestOptions) Headers() map[string]string {
-	return *j.headers
+func (b *baseRequestOptions) Headers() map[string]string {
+	return *b.headers
 }
 
-func (j *JsonRequestOptions) IsValueSet() bool {
-	return j.value != nil
+func (b *baseRequestOptions) IsOrderedHeadersSet() bool {
+	return b.orderedHeaders != nil
 }
-func (j *JsonRequestOptions) SetValue(bytes []byte) {
-	j.value = &bytes
+
+// SetOrderedHeaders sets headers that replace the runner headers with the same keys and are sent
+// in their order. The request then uses HTTP/1.1, over the pool of ordered connections.
+func (b *baseRequestOptions) SetOrderedHeaders(headers Headers) {
+	headers = headers.Clone()
+	b.orderedHeaders = &headers
 }
-func (j *JsonRequestOptions) Value() []byte {
-	return *j.value
+func (b *baseRequestOptions) OrderedHeaders() Headers {
+	return *b.orderedHeaders
 }
 
-func (j *JsonRequestOptions) IsRetryOptionSet() bool {
-	return j.retryCount != nil
+func (b *baseRequestOptions) IsQueryParamsSet() bool {
+	return b.queryParams != nil
 }
-func (j *JsonRequestOptions) SetRetryOption(count int) {
-	j.retryCount = &count
+
+// SetQueryParams sets query parameters added to the url, after any query the url already has.
+func (b *baseRequestOptions) SetQueryParams(params url.Values) {
+	b.queryParams = &params
 }
-func (j *JsonRequestOptions) RetryOption() int {
-	return *j.retryCount
+func (b *baseRequestOptions) QueryParams() url.Values {
+	return *b.queryParams
 }
 
-func (j *JsonRequestOptions) IsTimeoutOptionSet() bool {
-	return j.timeout != nil
+func (b *baseRequestOptions) IsRetryOptionSet() bool {
+	return b.retryCount != nil
 }
-func (j *JsonRequestOptions) SetTimeoutOption(timeout time.Duration) {
-	j.timeout = &timeout
+func (b *baseRequestOptions) SetRetryOption(count int) {
+	b.retryCount = &count
 }
-func (j *JsonRequestOptions) TimeoutOption() time.Duration {
-	return *j.timeout
+func (b *baseRequestOptions) RetryOption() int {
+	return *b.retryCount
 }
 
-func (j *JsonRequestOptions) IsFollowRedirectOptionSet() bool {
-	return j.followRedirect != nil
+func (b *baseRequestOptions) IsRetryPolicyOptionSet() bool {
+	return b.retryPolicy != nil
 }
-func (j *JsonRequestOptions) SetFollowRedirectOption(follow bool) {
-	j.followRedirect = &follow
+func (b *baseRequestOptions) SetRetryPolicyOption(policy RetryPolicy) {
+	b.retryPolicy = &policy
 }
-func (j *JsonRequestOptions) FollowRedirectOption() bool {
-	return *j.followRedirect
+func (b *baseRequestOptions) RetryPolicyOption() RetryPolicy {
+	return *b.retryPolicy
 }
 
-func NewJsonRequestOptions(url string) IJsonRequestOptions {
-	return &JsonRequestOptions{url: url}
+func (b *baseRequestOptions) IsTimeoutOptionSet() bool {
+	return b.timeout != nil
 }
-
-//
-
-type IHtmlRequestOptions interface {
-	IBaseRequest
-
-	IsValueSet() bool
-	SetValue(bytes []byte)
-	Value() []byte
+func (b *baseRequestOptions) SetTimeoutOption(timeout time.Duration) {
+	b.timeout = &timeout
+}
+func (b *baseRequestOptions) TimeoutOption() time.Duration {
+	return *b.timeout
 }
 
-type HtmlRequestOptions struct {
-	url            string
-	value          *[]byte
-	headers        *map[string]string
-	retryCount     *int
-	timeout        *time.Duration
-	followRedirect *bool
+func (b *baseRequestOptions) IsFollowRedirectOptionSet() bool {
+	return b.followRedirect != nil
+}
+func (b *baseRequestOptions) SetFollowRedirectOption(follow bool) {
+	b.followRedirect = &follow
+}
+func (b *baseRequestOptions) FollowRedirectOption() bool {
+	return *b.followRedirect
 }
 
-func (h *HtmlRequestOptions) Url() string {
-	return h.url
+func (b *baseRequestOptions) IsErrorOnStatusOptionSet() bool {
+	return b.errorOnStatus != nil
 }
 
-func (h *HtmlRequestOptions) IsHeadersSet() bool {
-	return h.headers != nil
+// SetErrorOnStatusOption decides whether a 4xx or 5xx response returns a *HttpError next to the response.
+func (b *baseRequestOptions) SetErrorOnStatusOption(errorOnStatus bool) {
+	b.errorOnStatus = &errorOnStatus
 }
-func (h *HtmlRequestOptions) SetHeaders(headers map[string]string) {
-	h.headers = &headers
+func (b *baseRequestOptions) ErrorOnStatusOption() bool {
+	return *b.errorOnStatus
 }
-func (h *HtmlRequestOptions) Headers() map[string]string {
-	return *h.headers
+
+func (b *baseRequestOptions) IsForwarderSet() bool {
+	return b.forwarder != nil
 }
 
-func (h *HtmlRequestOptions) IsValueSet() bool {
-	return h.value != nil
+// SetForwarder sends the request through the forwarder of WithForwarders with this name.
+func (b *baseRequestOptions) SetForwarder(name string) {
+	b.forwarder = &name
 }
-func (h *HtmlRequestOptions) SetValue(bytes []byte) {
-	h.value = &bytes
+func (b *baseRequestOptions) Forwarder() string {
+	return *b.forwarder
 }
-func (h *HtmlRequestOptions) Value() []byte {
-	return *h.value
+
+func (b *baseRequestOptions) IsForwarderIndexSet() bool {
+	return b.forwarderIndex != nil
 }
 
-func (h *HtmlRequestOptions) IsRetryOptionSet() bool {
-	return h.retryCount != nil
+// SetForwarderIndex sends the request through the forwarder of WithForwarders at index.
+func (b *baseRequestOptions) SetForwarderIndex(index int) {
+	b.forwarderIndex = &index
 }
-func (h *HtmlRequestOptions) SetRetryOption(count int) {
-	h.retryCount = &count
+func (b *baseRequestOptions) ForwarderIndex() int {
+	return *b.forwarderIndex
 }
-func (h *HtmlRequestOptions) RetryOption() int {
-	return *h.retryCount
+
+func (b *baseRequestOptions) IsStickyKeySet() bool {
+	return b.stickyKey != nil
 }
 
-func (h *HtmlRequestOptions) IsTimeoutOptionSet() bool {
-	return h.timeout != nil
+// SetStickyKey sends every request with key through the same forwarder, until a request
+// through it fails. A forwarder pinned by SetForwarder or SetForwarderIndex wins over the key.
+func (b *baseRequestOptions) SetStickyKey(key string) {
+	b.stickyKey = &key
 }
-func (h *HtmlRequestOptions) SetTimeoutOption(timeout time.Duration) {
-	h.timeout = &timeout
+func (b *baseRequestOptions) StickyKey() string {
+	return *b.stickyKey
 }
-func (h *HtmlRequestOptions) TimeoutOption() time.Duration {
-	return *h.timeout
+
+//
+
+type IJsonRequestOptions interface {
+	IBaseRequest
+
+	IsValueSet() bool
+	SetValue(bytes []byte)
+	Value() []byte
+
+	// JsonRequest does nothing, it tells JSON options from IHtmlRequestOptions, which have the
+	// same other methods, so that Do sends them as JSON.
+	JsonRequest()
 }
 
-func (h *HtmlRequestOptions) IsFollowRedirectOptionSet() bool {
-	return h.followRedirect != nil
+type JsonRequestOptions struct {
+	baseRequestOptions
+	value *[]byte
 }
-func (h *HtmlRequestOptions) SetFollowRedirectOption(follow bool) {
-	h.followRedirect = &follow
+
+func (j *JsonRequestOptions) IsValueSet() bool {
+	return j.value != nil
 }
-func (h *HtmlRequestOptions) FollowRedirectOption() bool {
-	return *h.followRedirect
+func (j *JsonRequestOptions) SetValue(bytes []byte) {
+	j.value = &bytes
 }
+func (j *JsonRequestOptions) Value() []byte {
+	return *j.value
+}
+func (j *JsonRequestOptions) JsonRequest() {}
 
-func NewHtmlRequestOptions(url string) IHtmlRequestOptions {
-	return &HtmlRequestOptions{url: url}
+func NewJsonRequestOptions(url string) IJsonRequestOptions {
+	return &JsonRequestOptions{baseRequestOptions: baseRequestOptions{url: url}}
 }
 
 //
 
-type FileInfo struct {
-	fileName string
-	reader   io.Reader
+type IHtmlRequestOptions interface {
+	IBaseRequest
+
+	IsValueSet() bool
+	SetValue(bytes []byte)
+	Value() []byte
 }
 
-func BuildFileInfo(file *os.File) FileInfo {
-	return FileInfo{
-		fileName: filepath.Base(file.Name()),
-		reader:   bufio.NewReader(file),
-	}
+type HtmlRequestOptions struct {
+	baseRequestOptions
+	value *[]byte
+}
+
+func (h *HtmlRequestOptions) IsValueSet() bool {
+	return h.value != nil
 }
+func (h *HtmlRequestOptions) SetValue(bytes []byte) {
+	h.value = &bytes
+}
+func (h *HtmlRequestOptions) Value() []byte {
+	return *h.value
+}
+
+func NewHtmlRequestOptions(url string) IHtmlRequestOptions {
+	return &HtmlRequestOptions{baseRequestOptions: baseRequestOptions{url: url}}
+}
+
+//
 
 type IFormRequestOptions interface {
 	IBaseRequest
+	ITransferRequestOptions
 
 	IsValuesSet() bool
 	SetValues(values map[string]string)
@@ -226,30 +328,18 @@ type IFormRequestOptions interface {
 	IsFilesSet() bool
 	SetFiles(files map[string]FileInfo)
 	Files() map[string]FileInfo
-}
-
-type FormRequestOptions struct {
-	url            string
-	values         *map[string]string
-	files          *map[string]FileInfo
-	headers        *map[string]string
-	retryCount     *int
-	timeout        *time.Duration
-	followRedirect *bool
-}
 
-func (f *FormRequestOptions) Url() string {
-	return f.url
+	IsStreamingOptionSet() bool
+	SetStreamingOption(streaming bool)
+	StreamingOption() bool
 }
 
-func (f *FormRequestOptions) IsHeadersSet() bool {
-	return f.headers != nil
-}
-func (f *FormRequestOptions) SetHeaders(headers map[string]string) {
-	f.headers = &headers
-}
-func (f *FormRequestOptions) Headers() map[string]string {
-	return *f.headers
+type FormRequestOptions struct {
+	baseRequestOptions
+	transferRequestOptions
+	values    *map[string]string
+	files     *map[string]FileInfo
+	streaming *bool
 }
 
 func (f *FormRequestOptions) IsValuesSet() bool {
@@ -272,119 +362,113 @@ func (f *FormRequestOptions) Files() map[string]FileInfo {
 	return *f.files
 }
 
-func (f *FormRequestOptions) IsRetryOptionSet() bool {
-	return f.retryCount != nil
-}
-func (f *FormRequestOptions) SetRetryOption(count int) {
-	f.retryCount = &count
-}
-func (f *FormRequestOptions) RetryOption() int {
-	return *f.retryCount
+func (f *FormRequestOptions) IsStreamingOptionSet() bool {
+	return f.streaming != nil
 }
 
-func (f *FormRequestOptions) IsTimeoutOptionSet() bool {
-	return f.timeout != nil
-}
-func (f *FormRequestOptions) SetTimeoutOption(timeout time.Duration) {
-	f.timeout = &timeout
+// SetStreamingOption makes a form with files be sent while the files are read, instead
+// of being encoded in memory first. The request is then sent with chunked encoding.
+func (f *FormRequestOptions) SetStreamingOption(streaming bool) {
+	f.streaming = &streaming
 }
-func (f *FormRequestOptions) TimeoutOption() time.Duration {
-	return *f.timeout
-}
-
-func (f *FormRequestOptions) IsFollowRedirectOptionSet() bool {
-	return f.followRedirect != nil
-}
-func (f *FormRequestOptions) SetFollowRedirectOption(follow bool) {
-	f.followRedirect = &follow
-}
-func (f *FormRequestOptions) FollowRedirectOption() bool {
-	return *f.followRedirect
+func (f *FormRequestOptions) StreamingOption() bool {
+	return *f.streaming
 }
 
 func NewFormRequestOptions(url string) IFormRequestOptions {
-	return &FormRequestOptions{url: url}
+	return &FormRequestOptions{baseRequestOptions: baseRequestOptions{url: url}}
 }
 
 //
 
 type IFileRequestOptions interface {
 	IBaseRequest
+	ITransferRequestOptions
 
 	FilePath() string
-}
 
-type FileRequestOptions struct {
-	url            string
-	filePath       string
-	headers        *map[string]string
-	retryCount     *int
-	timeout        *time.Duration
-	followRedirect *bool
+	IsResumeOptionSet() bool
+	SetResumeOption(resume bool)
+	ResumeOption() bool
+
+	IsChecksumOptionSet() bool
+	SetChecksumOption(checksum Checksum)
+	ChecksumOption() Checksum
+
+	IsSegmentsOptionSet() bool
+	SetSegmentsOption(segments int)
+	SegmentsOption() int
 }
 
-func (j *FileRequestOptions) Url() string {
-	return j.url
+type FileRequestOptions struct {
+	baseRequestOptions
+	transferRequestOptions
+	filePath string
+	resume   *bool
+	checksum *Checksum
+	segments *int
 }
 
 func (j *FileRequestOptions) FilePath() string {
 	return j.filePath
 }
 
-func (j *FileRequestOptions) IsHeadersSet() bool {
-	return j.headers != nil
-}
-func (j *FileRequestOptions) SetHeaders(headers map[string]string) {
-	j.headers = &headers
-}
-func (j *FileRequestOptions) Headers() map[string]string {
-	return *j.headers
+func (j *FileRequestOptions) IsResumeOptionSet() bool {
+	return j.resume != nil
 }
 
-func (j *FileRequestOptions) IsRetryOptionSet() bool {
-	return j.retryCount != nil
-}
-func (j *FileRequestOptions) SetRetryOption(count int) {
-	j.retryCount = &count
+// SetResumeOption decides whether a download continues from the part left by an earlier
+// attempt or call. Resuming is on by default, it needs an ETag or Last-Modified validator.
+func (j *FileRequestOptions) SetResumeOption(resume bool) {
+	j.resume = &resume
 }
-func (j *FileRequestOptions) RetryOption() int {
-	return *j.retryCount
+func (j *FileRequestOptions) ResumeOption() bool {
+	return *j.resume
 }
 
-func (j *FileRequestOptions) IsTimeoutOptionSet() bool {
-	return j.timeout != nil
+func (j *FileRequestOptions) IsChecksumOptionSet() bool {
+	return j.checksum != nil
 }
-func (j *FileRequestOptions) SetTimeoutOption(timeout time.Duration) {
-	j.timeout = &timeout
+
+// SetChecksumOption makes the download fail with ErrChecksumMismatch unless the file has the checksum.
+func (j *FileRequestOptions) SetChecksumOption(checksum Checksum) {
+	j.checksum = &checksum
 }
-func (j *FileRequestOptions) TimeoutOption() time.Duration {
-	return *j.timeout
+func (j *FileRequestOptions) ChecksumOption() Checksum {
+	return *j.checksum
 }
 
-func (j *FileRequestOptions) IsFollowRedirectOptionSet() bool {
-	return j.followRedirect != nil
+func (j *FileRequestOptions) IsSegmentsOptionSet() bool {
+	return j.segments != nil
 }
-func (j *FileRequestOptions) SetFollowRedirectOption(follow bool) {
-	j.followRedirect = &follow
+
+// SetSegmentsOption makes GetFile fetch up to segments byte ranges of the file at the same time.
+// Servers without range support get a single stream. Segmented downloads are not resumed by a later call.
+func (j *FileRequestOptions) SetSegmentsOption(segments int) {
+	j.segments = &segments
 }
-func (j *FileRequestOptions) FollowRedirectOption() bool {
-	return *j.followRedirect
+func (j *FileRequestOptions) SegmentsOption() int {
+	return *j.segments
 }
 
 func NewFileRequestOptions(url, filePath string) IFileRequestOptions {
-	return &FileRequestOptions{url: url, filePath: filePath}
+	return &FileRequestOptions{baseRequestOptions: baseRequestOptions{url: url}, filePath: filePath}
 }
 
-func integrateCookies(requestOptions IBaseRequest, request *resty.Request, cookieJar []*http.Cookie) error {
-	parsedUrl, err := url.Parse(requestOptions.Url())
+// integrateCookies adds the cookies of cookieJar that may be sent to requestUrl to request, the
+// first of cookies sharing Domain, Name and Path wins. Cookies of response.Cookies() carry no Domain
+// and are not sent; a jar (see WithCookieJar and NewCookieJar) keeps response cookies.
+func integrateCookies(requestUrl string, request *resty.Request, cookieJar []*http.Cookie) error {
+	parsedUrl, err := url.Parse(requestUrl)
 	if err != nil {
 		return err
 	}
 
+	now := time.Now()
 	cookieJarMap := make(map[string]*http.Cookie)
 
 	for _, cookie := range cookieJar {
-		if strings.HasSuffix(parsedUrl.Host, strings.TrimPrefix(cookie.Domain, ".")) {
+		if cookieMatchesUrl(cookie, parsedUrl, now) {
 			cookieComplexKey := cookie.Domain + cookie.Name + cookie.Path
 			if _, present := cookieJarMap[cookieComplexKey]; !present {
 				cookieJarMap[cookieComplexKey] = cookie
@@ -398,3 +482,155 @@ func integrateCookies(requestOptions IBaseRequest, request *resty.Request, cooki
 
 	return err
 }
+
+// cookieMatchesUrl reports whether cookie may be sent to u following RFC 6265 section 5.4.
+// A cookie without Domain is host-only for the host that set it, which is unknown here,
+// so such cookies are never sent; use a cookie jar (see WithCookieJar) to keep them.
+// MaxAge > 0 is relative to the moment the cookie was received, which is not known either,
+// so only Expires and a negative MaxAge make a cookie expired.
+func cookieMatchesUrl(cookie *http.Cookie, u *url.URL, now time.Time) bool {
+	if cookie.MaxAge < 0 {
+		return false
+	}
+	if cookie.MaxAge == 0 && !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+		return false
+	}
+
+	if cookie.Secure && u.Scheme != "https" && u.Scheme != "wss" {
+		return false
+	}
+
+	return cookieDomainMatch(u.Hostname(), cookie.Domain) && cookiePathMatch(u.Path, cookie.Path)
+}
+
+func cookieDomainMatch(host, domain string) bool {
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+
+	if len(host) == 0 || len(domain) == 0 {
+		return false
+	}
+	if host == domain {
+		return true
+	}
+
+	// a domain cookie never matches an ip address, only its sub domains match otherwise
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+func cookiePathMatch(requestPath, cookiePath string) bool {
+	if len(requestPath) == 0 || requestPath[0] != '/' {
+		requestPath = "/"
+	}
+	if len(cookiePath) == 0 || cookiePath[0] != '/' {
+		cookiePath = "/"
+	}
+
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// resolveRequestUrl appends requestUrl to baseUrl unless it is an absolute url already.
+func resolveRequestUrl(baseUrl, requestUrl string) string {
+	if len(baseUrl) == 0 {
+		return requestUrl
+	}
+	if parsedUrl, err := url.Parse(requestUrl); err == nil && parsedUrl.IsAbs() {
+		return requestUrl
+	}
+
+	return strings.TrimSuffix(baseUrl, "/") + "/" + strings.TrimPrefix(requestUrl, "/")
+}
+
+// addQueryParams appends params to the query of requestUrl, the query already in the url is kept as it is.
+func addQueryParams(requestUrl string, params url.Values) (string, error) {
+	if len(params) <= 0 {
+		return requestUrl, nil
+	}
+
+	parsedUrl, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if len(parsedUrl.RawQuery) > 0 {
+		parsedUrl.RawQuery += "&" + params.Encode()
+	} else {
+		parsedUrl.RawQuery = params.Encode()
+	}
+	parsedUrl.ForceQuery = false
+
+	return parsedUrl.String(), nil
+}
+
+// dialContext dials addr with dial, such as the Dial of a glider forwarder. The glider dialers
+// are not context-aware, so the dial runs in the background and is abandoned
+// (its connection closed once established) when ctx is done first. Errors of dial are
+// returned as a *dialError.
+func dialContext(ctx context.Context, dial func(network, addr string) (net.Conn, error), network, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan dialResult, 1)
+	go func() {
+		conn, err := dial(network, addr)
+		results <- dialResult{conn: conn, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			return nil, &dialError{err: result.err}
+		}
+		if conn, ok := result.conn.(*proxy.Conn); ok {
+			return &proxyConn{Conn: conn}, nil
+		}
+
+		return result.conn, nil
+	case <-ctx.Done():
+		go func() {
+			if result := <-results; result.conn != nil {
+				result.conn.Close()
+			}
+		}()
+
+		return nil, ctx.Err()
+	}
+}
+
+// proxyConn keeps the reader of a glider connection out of the pool of glider. proxy.Conn returns
+// it there on Close, even while another goroutine still reads from it, as http.Transport does when
+// it closes an idle connection.
+type proxyConn struct {
+	*proxy.Conn
+}
+
+func (p *proxyConn) Close() error {
+	return p.Conn.Conn.Close()
+}
+
+// dialError is an error of the dial of a connection, the handshake with a proxy included.
+// Nothing of the request reached the target.
+type dialError struct {
+	err error
+}
+
+func (d *dialError) Error() string {
+	return d.err.Error()
+}
+
+func (d *dialError) Unwrap() error {
+	return d.err
+}
@@ -1,6 +1,10 @@
 package http_runner
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
@@ -74,6 +78,105 @@ func TestDirectHttpPostJson(t *testing.T) {
 		}
 	})
 }
+func TestDirectHttpGetJsonWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // never answer, wait for the client to give up
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestDirectHttpGetJsonWithContext-Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+		defer cancel()
+
+		startedAt := time.Now()
+
+		_, err := directHttpRunner.GetJsonWithContext(ctx, NewJsonRequestOptions(server.URL))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("directHttpRunner.GetJsonWithContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(startedAt); elapsed > time.Second*5 {
+			t.Fatalf("directHttpRunner.GetJsonWithContext() returned after %v, want it to stop at the deadline", elapsed)
+		}
+	})
+	t.Run("TestDirectHttpGetJsonWithContext-Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := directHttpRunner.GetJsonWithContext(ctx, NewJsonRequestOptions(server.URL))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("directHttpRunner.GetJsonWithContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+// customJsonRequestOptions is an IJsonRequestOptions other than *JsonRequestOptions.
+type customJsonRequestOptions struct {
+	IJsonRequestOptions
+}
+
+func TestDoWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-content-type", r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withValue := func(requestOptions interface{ SetValue(bytes []byte) }) {
+		requestOptions.SetValue([]byte(`{"a":1}`))
+	}
+
+	jsonRequest := NewJsonRequestOptions(server.URL)
+	withValue(jsonRequest)
+	customJsonRequest := &customJsonRequestOptions{NewJsonRequestOptions(server.URL)}
+	withValue(customJsonRequest)
+	htmlRequest := NewHtmlRequestOptions(server.URL)
+	withValue(htmlRequest)
+
+	tests := []struct {
+		name           string
+		method         string
+		requestOptions IBaseRequest
+		want           string
+	}{
+		{"Json", http.MethodPatch, jsonRequest, "application/json"},
+		{"CustomJson", http.MethodPatch, customJsonRequest, "application/json"},
+		// resty detects the type of html values
+		{"Html", http.MethodPatch, htmlRequest, "text/plain; charset=utf-8"},
+		{"JsonWithoutValue", http.MethodHead, NewJsonRequestOptions(server.URL), ""},
+		{"JsonOptions", http.MethodOptions, NewJsonRequestOptions(server.URL), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestDoWithContext-"+tt.name, func(t *testing.T) {
+			response, err := directHttpRunner.DoWithContext(context.Background(), tt.method, tt.requestOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Header().Get("x-content-type"); got != tt.want {
+				t.Errorf("Content-Type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
 
 func TestJsonRequestOptions(t *testing.T) {
 	t.Run("TestJsonRequest-Url", func(t *testing.T) {
@@ -126,6 +229,19 @@ func TestJsonRequestOptions(t *testing.T) {
 			t.Errorf("jsonRequest.RetryOption() = %v, want %v", got, want)
 		}
 	})
+	t.Run("TestJsonRequest-RetryPolicyOption", func(t *testing.T) {
+		want := RetryPolicy{MinBackoff: time.Second, RetryOnStatus: []int{429}}
+
+		jsonRequest := NewJsonRequestOptions("https://httpbin.org/get")
+		jsonRequest.SetRetryPolicyOption(want)
+
+		if got := jsonRequest.IsRetryPolicyOptionSet(); got != true {
+			t.Errorf("jsonRequest.IsRetryPolicyOptionSet() = %v, want %v", got, true)
+		}
+		if got := jsonRequest.RetryPolicyOption(); !reflect.DeepEqual(got, want) {
+			t.Errorf("jsonRequest.RetryPolicyOption() = %v, want %v", got, want)
+		}
+	})
 	t.Run("TestJsonRequestOptions-FollowRedirectOption", func(t *testing.T) {
 		want := true
 
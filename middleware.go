@@ -0,0 +1,106 @@
+package http_runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Middleware hooks into every attempt of the requests of a runner, see WithMiddleware. Any of
+// its functions may be nil.
+//
+// BeforeRequest sees the request as the runner built it: request.Method and request.URL are
+// final, query parameters included, and so are the headers of the runner and of the request.
+// Headers added while sending are not in request.Header yet: the Cookie header, made of
+// request.Cookies and of the cookies of the jar, and the default User-Agent of resty when the
+// request sets none. The body is request.Body, or request.FormData for forms; streamed
+// uploads and multipart files are not visible. Changes to the request are sent. Returning a
+// response, such as one of NewSyntheticResponse, or an error skips sending the request and the
+// BeforeRequest of the middlewares after this one.
+//
+// AfterResponse sees the response, it returns an error to fail the attempt. OnError sees the
+// error of an attempt, it returns the error to report, or a response to recover with; returning
+// neither keeps the error. The *HttpError of WithErrorOnStatus is not an error of an attempt,
+// it is made for the final response after the retries: OnError does not see it, AfterResponse
+// sees the response with its status.
+//
+// BeforeRequest runs in the order of the middlewares, AfterResponse and OnError in reverse
+// order, and only for the middlewares whose BeforeRequest ran without a result.
+type Middleware struct {
+	BeforeRequest func(request *resty.Request) (*resty.Response, error)
+	AfterResponse func(response *resty.Response) error
+	OnError       func(request *resty.Request, err error) (*resty.Response, error)
+}
+
+// NewSyntheticResponse builds a response to request that was never sent, for
+// Middleware.BeforeRequest to short-circuit with.
+func NewSyntheticResponse(request *resty.Request, statusCode int, header http.Header, body []byte) *resty.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	rawResponse := &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request.RawRequest,
+	}
+
+	response := &resty.Response{Request: request, RawResponse: rawResponse}
+
+	return response.SetBody(body)
+}
+
+// sendWithMiddlewares sends request to url through middlewares, handleResponse taking the
+// response before the AfterResponse of the middlewares.
+func sendWithMiddlewares(request *resty.Request, method, url string, middlewares []Middleware, handleResponse func(response *resty.Response) error) (*resty.Response, error) {
+	request.Method = method
+	request.URL = url
+
+	var (
+		response *resty.Response
+		err      error
+	)
+
+	// ran counts the middlewares whose BeforeRequest let the request through
+	ran := 0
+	for _, middleware := range middlewares {
+		if middleware.BeforeRequest != nil {
+			if response, err = middleware.BeforeRequest(request); response != nil || err != nil {
+				break
+			}
+		}
+		ran++
+	}
+
+	if response == nil && err == nil {
+		response, err = request.Execute(request.Method, request.URL)
+	}
+	if err == nil && handleResponse != nil {
+		err = handleResponse(response)
+	}
+
+	for i := ran - 1; i >= 0; i-- {
+		if err == nil && middlewares[i].AfterResponse != nil {
+			err = middlewares[i].AfterResponse(response)
+		}
+		if err != nil && middlewares[i].OnError != nil {
+			recovered, onErrorErr := middlewares[i].OnError(request, err)
+			if onErrorErr != nil {
+				err = onErrorErr
+			} else if recovered != nil {
+				response, err = recovered, nil
+			}
+		}
+	}
+
+	return response, err
+}
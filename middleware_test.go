@@ -0,0 +1,211 @@
+package http_runner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+	"github.com/go-resty/resty/v2"
+)
+
+func TestMiddleware(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("x-signature", r.Header.Get("x-signature"))
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestMiddleware-Sign", func(t *testing.T) {
+		sign := Middleware{BeforeRequest: func(request *resty.Request) (*resty.Response, error) {
+			request.SetHeader("x-signature", fmt.Sprintf("%v %v %s", request.Method, request.URL, request.Body))
+			return nil, nil
+		}}
+
+		runner, err := NewHttpRunner(directDialer, WithBaseUrl(server.URL), WithMiddleware(sign))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jsonRequest := NewJsonRequestOptions("/sign")
+		jsonRequest.SetQueryParams(url.Values{"q": {"1"}})
+		jsonRequest.SetValue([]byte(`{"a":1}`))
+
+		response, err := runner.PostJson(jsonRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := fmt.Sprintf(`POST %v/sign?q=1 {"a":1}`, server.URL); response.Header().Get("x-signature") != want {
+			t.Errorf("x-signature = %v, want %v", response.Header().Get("x-signature"), want)
+		}
+	})
+	t.Run("TestMiddleware-ShortCircuit", func(t *testing.T) {
+		var order []string
+		trace := func(name string) Middleware {
+			return Middleware{
+				BeforeRequest: func(request *resty.Request) (*resty.Response, error) {
+					order = append(order, "before "+name)
+					return nil, nil
+				},
+				AfterResponse: func(response *resty.Response) error {
+					order = append(order, "after "+name)
+					return nil
+				},
+			}
+		}
+		cache := Middleware{
+			BeforeRequest: func(request *resty.Request) (*resty.Response, error) {
+				return NewSyntheticResponse(request, http.StatusOK, http.Header{"X-Cache": {"hit"}}, []byte("cached")), nil
+			},
+			AfterResponse: func(response *resty.Response) error {
+				order = append(order, "after cache")
+				return nil
+			},
+		}
+
+		runner, err := NewHttpRunner(directDialer, WithMiddleware(trace("a"), trace("b")), WithMiddleware(cache, trace("c")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := atomic.LoadInt32(&hits)
+		response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if atomic.LoadInt32(&hits) != before {
+			t.Error("the server got a short-circuited request")
+		}
+		if response.StatusCode() != http.StatusOK || response.String() != "cached" || response.Header().Get("X-Cache") != "hit" {
+			t.Errorf("response = %v %q %v, want the synthetic response", response.StatusCode(), response.String(), response.Header())
+		}
+		if want := "[before a before b after b after a]"; fmt.Sprint(order) != want {
+			t.Errorf("order = %v, want %v", order, want)
+		}
+	})
+	t.Run("TestMiddleware-OnError", func(t *testing.T) {
+		errRejected := errors.New("rejected")
+		reject := Middleware{AfterResponse: func(response *resty.Response) error {
+			return errRejected
+		}}
+		recovering := Middleware{OnError: func(request *resty.Request, err error) (*resty.Response, error) {
+			if !errors.Is(err, errRejected) {
+				return nil, err
+			}
+			return NewSyntheticResponse(request, http.StatusAccepted, nil, []byte("recovered")), nil
+		}}
+
+		runner, err := NewHttpRunner(directDialer, WithRetryCount(0), WithMiddleware(reject))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(server.URL)); !errors.Is(err, errRejected) {
+			t.Errorf("error = %v, want %v", err, errRejected)
+		}
+
+		runner, err = NewHttpRunner(directDialer, WithRetryCount(0), WithMiddleware(recovering, reject))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode() != http.StatusAccepted || response.String() != "recovered" {
+			t.Errorf("response = %v %q, want the recovered response", response.StatusCode(), response.String())
+		}
+	})
+	t.Run("TestMiddleware-LaterHeaders", func(t *testing.T) {
+		headerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("x-cookie", r.Header.Get("Cookie"))
+			w.Header().Set("x-user-agent", r.Header.Get("User-Agent"))
+		}))
+		defer headerServer.Close()
+
+		var seenCookie, seenUserAgent string
+		inspect := Middleware{BeforeRequest: func(request *resty.Request) (*resty.Response, error) {
+			seenCookie, seenUserAgent = request.Header.Get("Cookie"), request.Header.Get("User-Agent")
+			return nil, nil
+		}}
+
+		runner, err := NewHttpRunner(directDialer, WithMiddleware(inspect))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jar, err := NewCookieJar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		serverUrl, _ := url.Parse(headerServer.URL)
+		jar.SetCookies(serverUrl, []*http.Cookie{{Name: "jar", Value: "1"}})
+
+		response, err := runner.WithCookieJar(jar).GetHtml(NewHtmlRequestOptions(headerServer.URL), &http.Cookie{Name: "argument", Value: "1", Domain: serverUrl.Hostname()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the cookies and the default User-Agent are added after BeforeRequest
+		if len(seenCookie) > 0 || len(seenUserAgent) > 0 {
+			t.Errorf("BeforeRequest saw Cookie %q and User-Agent %q, want neither", seenCookie, seenUserAgent)
+		}
+		if got := response.Header().Get("x-cookie"); !strings.Contains(got, "jar=1") || !strings.Contains(got, "argument=1") {
+			t.Errorf("sent Cookie = %q, want both cookies", got)
+		}
+		if got := response.Header().Get("x-user-agent"); !strings.HasPrefix(got, "go-resty") {
+			t.Errorf("sent User-Agent = %q, want the default of resty", got)
+		}
+	})
+	t.Run("TestMiddleware-ErrorOnStatus", func(t *testing.T) {
+		statusServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer statusServer.Close()
+
+		var (
+			afterStatus int
+			onErrorRan  bool
+		)
+		observe := Middleware{
+			AfterResponse: func(response *resty.Response) error {
+				afterStatus = response.StatusCode()
+				return nil
+			},
+			OnError: func(request *resty.Request, err error) (*resty.Response, error) {
+				onErrorRan = true
+				return nil, err
+			},
+		}
+
+		runner, err := NewHttpRunner(directDialer, WithRetryCount(0), WithErrorOnStatus(true), WithMiddleware(observe))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the *HttpError is made after the middlewares ran, they only see the response
+		var httpError *HttpError
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(statusServer.URL)); !errors.As(err, &httpError) {
+			t.Fatalf("error = %v, want a *HttpError", err)
+		}
+		if onErrorRan {
+			t.Error("OnError ran for the *HttpError of WithErrorOnStatus")
+		}
+		if afterStatus != http.StatusInternalServerError {
+			t.Errorf("AfterResponse saw status %v, want %v", afterStatus, http.StatusInternalServerError)
+		}
+	})
+}
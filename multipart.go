@@ -0,0 +1,179 @@
+package http_runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// FileInfo is a file uploaded with a form. Its content is opened again for every attempt,
+// so a retried upload sends the whole file again.
+type FileInfo struct {
+	fileName    string
+	contentType string
+	open        func() (io.ReadCloser, error)
+}
+
+// BuildFileInfo uploads an opened file from its current offset.
+func BuildFileInfo(file *os.File) FileInfo {
+	return NewFileInfoFromReadSeeker(filepath.Base(file.Name()), file)
+}
+
+// NewFileInfoFromPath uploads the file at path, which is opened when the request is sent.
+func NewFileInfoFromPath(path string) FileInfo {
+	return FileInfo{
+		fileName: filepath.Base(path),
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+}
+
+// NewFileInfoFromBytes uploads data as a file named fileName.
+func NewFileInfoFromBytes(fileName string, data []byte) FileInfo {
+	return FileInfo{
+		fileName: fileName,
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
+
+// NewFileInfoFromReadSeeker uploads reader from its current offset, rewinding it to that
+// offset before every attempt. The reader must not be shared by concurrent requests.
+func NewFileInfoFromReadSeeker(fileName string, reader io.ReadSeeker) FileInfo {
+	offset, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		offset = 0
+	}
+
+	return FileInfo{
+		fileName: fileName,
+		open: func() (io.ReadCloser, error) {
+			if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+
+			return io.NopCloser(reader), nil
+		},
+	}
+}
+
+// WithContentType returns a copy of the file info sent with contentType, which is
+// otherwise detected from the first bytes of the file.
+func (f FileInfo) WithContentType(contentType string) FileInfo {
+	f.contentType = contentType
+
+	return f
+}
+
+// setMultipartBody encodes values and files as the multipart body of request. When streaming,
+// the body is written while the request is sent; the writer stops once ctx is done.
+// Streamed bodies and bodies reporting progress bypass resty, which would buffer them.
+func setMultipartBody(ctx context.Context, request *resty.Request, values map[string]string, files map[string]FileInfo, streaming bool, transfer transferSettings) error {
+	if !streaming {
+		var body bytes.Buffer
+
+		writer := multipart.NewWriter(&body)
+		if err := writeMultipart(writer, values, files); err != nil {
+			return err
+		}
+
+		if transfer.isSet() {
+			setRequestBody(request, newTransferReader(ctx, bytes.NewReader(body.Bytes()), transfer, 0, int64(body.Len())), int64(body.Len()))
+		} else {
+			request.SetBody(body.Bytes())
+		}
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		return nil
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+
+	go func() {
+		pipeWriter.CloseWithError(writeMultipart(writer, values, files))
+	}()
+	go func() {
+		<-ctx.Done()
+		pipeReader.CloseWithError(ctx.Err())
+	}()
+
+	setRequestBody(request, newTransferReader(ctx, pipeReader, transfer, 0, -1), -1)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return nil
+}
+
+func writeMultipart(writer *multipart.Writer, values map[string]string, files map[string]FileInfo) error {
+	for _, key := range sortedKeys(values) {
+		if err := writer.WriteField(key, values[key]); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range sortedKeys(files) {
+		if err := writeMultipartFile(writer, key, files[key]); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeMultipartFile(writer *multipart.Writer, fieldName string, fileInfo FileInfo) error {
+	if fileInfo.open == nil {
+		return fmt.Errorf("file %v of field %v has no content", fileInfo.fileName, fieldName)
+	}
+
+	reader, err := fileInfo.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	bufferedReader := bufio.NewReader(reader)
+
+	contentType := fileInfo.contentType
+	if len(contentType) <= 0 {
+		head, _ := bufferedReader.Peek(512)
+		contentType = http.DetectContentType(head)
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(fieldName), quoteEscaper.Replace(fileInfo.fileName)))
+	header.Set("Content-Type", contentType)
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, bufferedReader)
+
+	return err
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
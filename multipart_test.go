@@ -0,0 +1,126 @@
+package http_runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestPostFormFilesRetry(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	var hits int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		file, header, err := r.FormFile("upload")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		received, _ := io.ReadAll(file)
+		if !bytes.Equal(received, content) || r.FormValue("name") != "test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// the first attempt fails after the whole upload was received
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("x-file-name", header.Filename)
+		w.Header().Set("x-content-type", header.Header.Get("Content-Type"))
+		w.Header().Set("x-transfer-encoding", fmt.Sprint(r.TransferEncoding))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer, WithRetryCount(1), WithRetryPolicy(RetryPolicy{
+		MinBackoff:         time.Millisecond,
+		RetryOnStatus:      []int{http.StatusServiceUnavailable},
+		RetryNonIdempotent: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		fileInfo        FileInfo
+		streaming       bool
+		wantFileName    string
+		wantContentType string
+	}{
+		{name: "Path", fileInfo: NewFileInfoFromPath(filePath), wantFileName: "upload.bin", wantContentType: "text/plain; charset=utf-8"},
+		{name: "Bytes", fileInfo: NewFileInfoFromBytes("bytes.bin", content).WithContentType("application/x-test"), wantFileName: "bytes.bin", wantContentType: "application/x-test"},
+		{name: "ReadSeeker", fileInfo: NewFileInfoFromReadSeeker("seeker.bin", bytes.NewReader(content)), wantFileName: "seeker.bin", wantContentType: "text/plain; charset=utf-8"},
+		{name: "File", fileInfo: BuildFileInfo(file), wantFileName: "upload.bin", wantContentType: "text/plain; charset=utf-8"},
+		{name: "Streaming", fileInfo: NewFileInfoFromPath(filePath).WithContentType("application/octet-stream"), streaming: true, wantFileName: "upload.bin", wantContentType: "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestPostFormFilesRetry-"+tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+
+			formRequest := NewFormRequestOptions(server.URL)
+			formRequest.SetValues(map[string]string{"name": "test"})
+			formRequest.SetFiles(map[string]FileInfo{"upload": tt.fileInfo})
+			if tt.streaming {
+				formRequest.SetStreamingOption(true)
+			}
+
+			response, err := directHttpRunner.PostForm(formRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.StatusCode(); got != http.StatusOK {
+				t.Fatalf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+			}
+			if got := atomic.LoadInt32(&hits); got != 2 {
+				t.Errorf("attempts = %v, want %v", got, 2)
+			}
+			if got := response.Header().Get("x-file-name"); got != tt.wantFileName {
+				t.Errorf("file name = %v, want %v", got, tt.wantFileName)
+			}
+			if got := response.Header().Get("x-content-type"); got != tt.wantContentType {
+				t.Errorf("file Content-Type = %v, want %v", got, tt.wantContentType)
+			}
+			if got, want := response.Header().Get("x-transfer-encoding"), fmt.Sprint([]string{"chunked"}); tt.streaming != (got == want) {
+				t.Errorf("transfer encoding = %v, streaming %v", got, tt.streaming)
+			}
+		})
+	}
+}
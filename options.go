@@ -0,0 +1,290 @@
+package http_runner
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nadoo/glider/rule"
+)
+
+// Option configures a runner created by NewHttpRunner, NewDirectHttpRunner or NewProxyHttpRunner.
+type Option func(config *runnerConfig)
+
+type runnerConfig struct {
+	retryCount         int
+	retryPolicy        RetryPolicy
+	timeout            time.Duration
+	headers            map[string]string
+	orderedHeaders     Headers
+	profiles           *profileRotation
+	userAgent          string
+	redirectPolicy     RedirectPolicy
+	jsonRedirectPolicy RedirectPolicy
+	cookieJar          http.CookieJar
+	cookieJarSet       bool
+	tlsConfig          *tls.Config
+	tlsOptions         *TLSOptions
+	tlsHandshaker      TLSHandshaker
+	logger             resty.Logger
+	transportOptions   TransportOptions
+	http2Mode          HTTP2Mode
+	forwarders         []Forwarder
+	banRules           []BanRule
+	outcomeClassifiers []OutcomeClassifier
+	baseUrl            string
+	progress           ProgressFunc
+	rateLimit          int64
+	errorOnStatus      bool
+	middlewares        []Middleware
+}
+
+func newRunnerConfig(retryCount int, timeout time.Duration, options []Option) (runnerConfig, error) {
+	config := runnerConfig{
+		retryCount:         retryCount,
+		retryPolicy:        DefaultRetryPolicy,
+		timeout:            timeout,
+		headers:            DefaultHeaders,
+		redirectPolicy:     DefaultRedirectPolicy,
+		jsonRedirectPolicy: DefaultJsonRedirectPolicy,
+	}
+
+	for _, option := range options {
+		option(&config)
+	}
+
+	if config.tlsOptions != nil {
+		tlsConfig, err := config.tlsOptions.apply(config.tlsConfig)
+		if err != nil {
+			return config, err
+		}
+		config.tlsConfig = tlsConfig
+	}
+
+	return config, nil
+}
+
+// WithRetryCount sets how many times a failed request is retried, unless the request sets its own retry option.
+func WithRetryCount(count int) Option {
+	return func(config *runnerConfig) {
+		config.retryCount = count
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy, unless the request sets its own retry policy option.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(config *runnerConfig) {
+		config.retryPolicy = policy
+	}
+}
+
+// WithTimeout sets the timeout of every attempt, unless the request sets its own timeout option.
+func WithTimeout(timeout time.Duration) Option {
+	return func(config *runnerConfig) {
+		config.timeout = timeout
+	}
+}
+
+// WithHeaders replaces DefaultHeaders as the headers sent with every request.
+func WithHeaders(headers map[string]string) Option {
+	return func(config *runnerConfig) {
+		config.headers = headers
+	}
+}
+
+// WithOrderedHeaders replaces DefaultHeaders and WithHeaders as the headers sent with every request.
+// Requests of the runner send their headers in this order, followed by the headers of the request.
+// Such requests use HTTP/1.1 over connections pooled apart from the others, see orderedTransport.
+func WithOrderedHeaders(headers Headers) Option {
+	return func(config *runnerConfig) {
+		config.orderedHeaders = headers.Clone()
+	}
+}
+
+// WithBrowserProfile sends the headers of profile, in its order, with every request. It replaces
+// DefaultHeaders, WithHeaders and WithOrderedHeaders.
+func WithBrowserProfile(profile BrowserProfile) Option {
+	return WithBrowserProfiles(RotatePerSession, profile)
+}
+
+// WithBrowserProfiles sends the headers of one of profiles, in its order, with every request,
+// changing profile as rotation says. It replaces DefaultHeaders, WithHeaders and WithOrderedHeaders.
+func WithBrowserProfiles(rotation ProfileRotation, profiles ...BrowserProfile) Option {
+	return func(config *runnerConfig) {
+		if len(profiles) <= 0 {
+			config.profiles = nil
+			return
+		}
+
+		config.profiles = &profileRotation{
+			profiles: append([]BrowserProfile(nil), profiles...),
+			rotation: rotation,
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with every request, request headers may still override it.
+func WithUserAgent(userAgent string) Option {
+	return func(config *runnerConfig) {
+		config.userAgent = userAgent
+	}
+}
+
+// WithRedirectPolicy replaces DefaultRedirectPolicy, and DefaultJsonRedirectPolicy of the JSON methods.
+func WithRedirectPolicy(policy RedirectPolicy) Option {
+	return func(config *runnerConfig) {
+		config.redirectPolicy = policy
+		config.jsonRedirectPolicy = policy
+	}
+}
+
+// WithCookieJar makes the runner keep the cookies set by responses in jar, instead of the
+// NewCookieJar the runner keeps them in by default. A nil jar keeps no cookies, as it does for
+// IHttpRunner.WithCookieJar.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(config *runnerConfig) {
+		config.cookieJar = jar
+		config.cookieJarSet = true
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the transport.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(config *runnerConfig) {
+		config.tlsConfig = tlsConfig
+	}
+}
+
+// WithTLSOptions trusts extra CAs, presents client certificates, pins server public keys or
+// skips verification, on top of the configuration of WithTLSConfig. Certificate files are read
+// when the runner is created, which fails when they can not be loaded.
+func WithTLSOptions(options TLSOptions) Option {
+	return func(config *runnerConfig) {
+		config.tlsOptions = &options
+	}
+}
+
+// WithTLSHandshaker runs the TLS handshakes of the runner with handshaker, such as
+// ChromeTLSHandshaker, instead of crypto/tls. It replaces the handshakers of browser profiles.
+func WithTLSHandshaker(handshaker TLSHandshaker) Option {
+	return func(config *runnerConfig) {
+		config.tlsHandshaker = handshaker
+	}
+}
+
+// WithLogger replaces the resty logger, which by default only logs at logrus trace level.
+func WithLogger(logger resty.Logger) Option {
+	return func(config *runnerConfig) {
+		config.logger = logger
+	}
+}
+
+// WithMaxConnsPerHost limits the number of connections per host, zero means no limit.
+func WithMaxConnsPerHost(count int) Option {
+	return func(config *runnerConfig) {
+		config.transportOptions.MaxConnsPerHost = count
+	}
+}
+
+// WithTransportOptions tunes the connection pool, zero fields keep DefaultTransportOptions.
+// It replaces WithMaxConnsPerHost.
+func WithTransportOptions(options TransportOptions) Option {
+	return func(config *runnerConfig) {
+		config.transportOptions = options
+	}
+}
+
+// WithHTTP2 sets whether the runner speaks HTTP/2, HTTP2Off by default. Requests with ordered
+// headers, and the https requests of a TLSHandshaker, use HTTP/1.1 unless the handshaker offers
+// h2 through ALPN and the server picks it, as with the browser presets of NewUTLSHandshaker.
+func WithHTTP2(mode HTTP2Mode) Option {
+	return func(config *runnerConfig) {
+		config.http2Mode = mode
+	}
+}
+
+// WithForwarders names the upstreams requests can be pinned to with SetForwarder or
+// SetForwarderIndex. Requests with a sticky key take turns over them, requests not pinned
+// to any keep going through the dialer of the runner.
+func WithForwarders(forwarders ...Forwarder) Option {
+	return func(config *runnerConfig) {
+		config.forwarders = make([]Forwarder, 0, len(forwarders))
+		for _, forwarder := range forwarders {
+			if len(forwarder.Name) <= 0 {
+				forwarder.Name = forwarder.Dialer.Addr()
+			}
+			config.forwarders = append(config.forwarders, forwarder)
+		}
+	}
+}
+
+// WithOutcomeClassifiers records the outcome of every request on the forwarder that carried
+// it, judged by the first classifier with a verdict. Failed and banned forwarders are left out
+// of the rotation of the rule.Proxy dialer, and lose the sticky keys held on them.
+// Outcomes are recorded only when classifiers or ban rules are given, DefaultOutcomeClassifiers
+// being a good start.
+func WithOutcomeClassifiers(classifiers ...OutcomeClassifier) Option {
+	return func(config *runnerConfig) {
+		config.outcomeClassifiers = classifiers
+	}
+}
+
+// WithBanRules bans the forwarders of responses matching rules, they are checked before the
+// classifiers of WithOutcomeClassifiers.
+func WithBanRules(rules ...BanRule) Option {
+	return func(config *runnerConfig) {
+		config.banRules = rules
+	}
+}
+
+// WithBaseUrl sets the url that relative request urls are appended to.
+func WithBaseUrl(baseUrl string) Option {
+	return func(config *runnerConfig) {
+		config.baseUrl = baseUrl
+	}
+}
+
+// WithProgress reports the progress of every GetFile download and PostForm upload with files,
+// unless the request sets its own progress option.
+func WithProgress(progress ProgressFunc) Option {
+	return func(config *runnerConfig) {
+		config.progress = progress
+	}
+}
+
+// WithRateLimit caps the GetFile downloads and PostForm uploads with files of the runner and its
+// sessions at bytesPerSecond together. A request setting its own rate limit option is capped on
+// its own instead. Zero means no limit.
+func WithRateLimit(bytesPerSecond int64) Option {
+	return func(config *runnerConfig) {
+		config.rateLimit = bytesPerSecond
+	}
+}
+
+// WithErrorOnStatus makes every request answered with a 4xx or 5xx status return a *HttpError
+// next to the response, unless the request sets its own error on status option.
+func WithErrorOnStatus(errorOnStatus bool) Option {
+	return func(config *runnerConfig) {
+		config.errorOnStatus = errorOnStatus
+	}
+}
+
+// WithMiddleware adds middlewares running around every attempt of every request, after the
+// middlewares of earlier WithMiddleware options.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(config *runnerConfig) {
+		config.middlewares = append(config.middlewares, middlewares...)
+	}
+}
+
+// NewHttpRunner creates a runner dialing through dialer, with 2 retries, a 15 seconds
+// timeout and DefaultHeaders unless options say otherwise.
+func NewHttpRunner(dialer *rule.Proxy, options ...Option) (IHttpRunner, error) {
+	config, err := newRunnerConfig(2, time.Second*15, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHttpRunner(dialer, config)
+}
@@ -0,0 +1,108 @@
+package http_runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestNewHttpRunner(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-path", r.URL.Path)
+		w.Header().Set("x-user-agent", r.Header.Get("user-agent"))
+		w.Header().Set("x-test", r.Header.Get("x-test"))
+
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Millisecond * 300)
+		}
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestNewHttpRunner-Options", func(t *testing.T) {
+		runner, err := NewHttpRunner(directDialer,
+			WithBaseUrl(server.URL+"/api/"),
+			WithHeaders(map[string]string{"x-test": "true"}),
+			WithUserAgent("test-agent/1.0"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := runner.GetHtml(NewHtmlRequestOptions("/users"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.Header().Get("x-path"); got != "/api/users" {
+			t.Errorf("path = %v, want %v", got, "/api/users")
+		}
+		if got := response.Header().Get("x-user-agent"); got != "test-agent/1.0" {
+			t.Errorf("user-agent = %v, want %v", got, "test-agent/1.0")
+		}
+		if got := response.Header().Get("x-test"); got != "true" {
+			t.Errorf("x-test = %v, want %v", got, "true")
+		}
+		if _, present := DefaultHeaders["User-Agent"]; present {
+			t.Errorf("WithUserAgent() changed DefaultHeaders")
+		}
+
+		// absolute urls ignore the base url
+		response, err = runner.GetHtml(NewHtmlRequestOptions(server.URL + "/absolute"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.Header().Get("x-path"); got != "/absolute" {
+			t.Errorf("path = %v, want %v", got, "/absolute")
+		}
+	})
+	t.Run("TestNewHttpRunner-Timeout", func(t *testing.T) {
+		runner, err := NewHttpRunner(directDialer, WithTimeout(time.Millisecond*50), WithRetryCount(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + "/slow")); err == nil {
+			t.Fatal("runner.GetHtml() error = nil, want timeout")
+		}
+	})
+	t.Run("TestNewHttpRunner-TLSConfig", func(t *testing.T) {
+		tlsServer := httptest.NewTLSServer(handler)
+		defer tlsServer.Close()
+
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(tlsServer.Certificate())
+
+		runner, err := NewProxyHttpRunner(directDialer, WithTLSConfig(&tls.Config{RootCAs: rootCAs}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := runner.GetHtml(NewHtmlRequestOptions(tlsServer.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.StatusCode(); got != http.StatusOK {
+			t.Errorf("response.StatusCode() = %v, want %v", got, http.StatusOK)
+		}
+
+		// without the test certificate the handshake has to fail
+		runner, err = NewProxyHttpRunner(directDialer, WithRetryCount(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(tlsServer.URL)); err == nil {
+			t.Fatal("runner.GetHtml() error = nil, want certificate error")
+		}
+	})
+}
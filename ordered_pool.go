@@ -0,0 +1,302 @@
+package http_runner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// orderedConnKey groups the connections of orderedTransport: a connection made by one TLS
+// handshaker is not reused for a request asking for another.
+type orderedConnKey struct {
+	scheme     string
+	addr       string
+	handshaker TLSHandshaker
+}
+
+// orderedConn is a connection of orderedTransport.
+type orderedConn struct {
+	net.Conn
+	key    orderedConnKey
+	reader *bufio.Reader
+	writer *bufio.Writer
+	// read counts the bytes read, a request whose response got none may be sent again
+	read int64
+
+	// the fields below are guarded by the mutex of the pool
+	idleTimer *time.Timer
+	watchDone chan struct{}
+	broken    bool
+}
+
+func (o *orderedConn) Read(p []byte) (int, error) {
+	n, err := o.Conn.Read(p)
+	o.read += int64(n)
+
+	return n, err
+}
+
+// watch reads from the idle connection until it is taken: a server closing the connection or
+// sending something unasked makes it useless.
+func (o *orderedConn) watch(pool *orderedPool) {
+	defer close(o.watchDone)
+
+	_, err := o.reader.Peek(1)
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return // interrupted by take
+	}
+
+	if pool.removeIdle(o) {
+		pool.remove(o)
+		return
+	}
+
+	pool.mutex.Lock()
+	o.broken = true
+	pool.mutex.Unlock()
+}
+
+// take stops the watch of a connection removed from the idle ones, it reports whether the
+// connection can still be used.
+func (o *orderedConn) take(pool *orderedPool) bool {
+	// a deadline in the past interrupts the read of watch
+	o.Conn.SetReadDeadline(time.Unix(1, 0))
+	<-o.watchDone
+	o.Conn.SetReadDeadline(time.Time{})
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	return !o.broken
+}
+
+// orderedPool keeps the idle connections of orderedTransport and counts the open ones. HTTP/2
+// connections are kept apart, each serves many requests at once and is not counted.
+type orderedPool struct {
+	mutex sync.Mutex
+	hosts map[orderedConnKey]*orderedHost
+	idle  int
+	h2    map[orderedConnKey][]*http2.ClientConn
+}
+
+type orderedHost struct {
+	idle  []*orderedConn
+	conns int
+	// released is closed and replaced whenever a connection is released, for the requests
+	// waiting for one under MaxConnsPerHost
+	released chan struct{}
+}
+
+func (o *orderedPool) host(key orderedConnKey) *orderedHost {
+	if o.hosts == nil {
+		o.hosts = map[orderedConnKey]*orderedHost{}
+	}
+
+	host, ok := o.hosts[key]
+	if !ok {
+		host = &orderedHost{released: make(chan struct{})}
+		o.hosts[key] = host
+	}
+
+	return host
+}
+
+// get returns an idle connection for key or a new one made by connect, waiting while the host
+// has maxConns open. It reports whether the connection was reused.
+func (o *orderedPool) get(ctx context.Context, key orderedConnKey, maxConns int, connect func(context.Context, orderedConnKey) (net.Conn, error)) (*orderedConn, bool, error) {
+	for {
+		o.mutex.Lock()
+		host := o.host(key)
+
+		if count := len(host.idle); count > 0 {
+			conn := host.idle[count-1]
+			host.idle = host.idle[:count-1]
+			o.idle--
+			if conn.idleTimer != nil {
+				conn.idleTimer.Stop()
+			}
+			o.mutex.Unlock()
+
+			if conn.take(o) {
+				return conn, true, nil
+			}
+
+			o.remove(conn)
+			continue
+		}
+
+		if maxConns <= 0 || host.conns < maxConns {
+			host.conns++
+			o.mutex.Unlock()
+
+			netConn, err := connect(ctx, key)
+			if err != nil {
+				o.release(key)
+				return nil, false, err
+			}
+
+			conn := &orderedConn{Conn: netConn, key: key}
+			conn.reader = bufio.NewReader(conn)
+			conn.writer = bufio.NewWriter(conn)
+
+			return conn, false, nil
+		}
+
+		released := host.released
+		o.mutex.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// put keeps conn idle within the limits of options, it closes the connection otherwise.
+func (o *orderedPool) put(conn *orderedConn, options TransportOptions) {
+	o.mutex.Lock()
+
+	host := o.host(conn.key)
+	if options.DisableKeepAlives || len(host.idle) >= options.MaxIdleConnsPerHost || (options.MaxIdleConns > 0 && o.idle >= options.MaxIdleConns) {
+		o.mutex.Unlock()
+		o.remove(conn)
+		return
+	}
+
+	conn.watchDone = make(chan struct{})
+	conn.broken = false
+	if options.IdleConnTimeout > 0 {
+		conn.idleTimer = time.AfterFunc(options.IdleConnTimeout, func() {
+			if o.removeIdle(conn) {
+				o.remove(conn)
+			}
+		})
+	}
+
+	host.idle = append(host.idle, conn)
+	o.idle++
+	o.signal(host)
+
+	o.mutex.Unlock()
+
+	go conn.watch(o)
+}
+
+// removeIdle takes conn out of the idle connections, it reports whether conn was idle.
+func (o *orderedPool) removeIdle(conn *orderedConn) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	host, ok := o.hosts[conn.key]
+	if !ok {
+		return false
+	}
+	for i, idle := range host.idle {
+		if idle == conn {
+			host.idle = append(host.idle[:i], host.idle[i+1:]...)
+			o.idle--
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove closes conn and frees its place.
+func (o *orderedPool) remove(conn *orderedConn) {
+	conn.Close()
+	o.release(conn.key)
+}
+
+func (o *orderedPool) release(key orderedConnKey) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	host := o.host(key)
+	host.conns--
+	o.signal(host)
+
+	if host.conns <= 0 {
+		delete(o.hosts, key)
+	}
+}
+
+func (o *orderedPool) signal(host *orderedHost) {
+	close(host.released)
+	host.released = make(chan struct{})
+}
+
+// getH2 returns an HTTP/2 connection for key able to take a request, nil when there is none.
+// Connections closed or closing are forgotten.
+func (o *orderedPool) getH2(key orderedConnKey) *http2.ClientConn {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	var found *http2.ClientConn
+	open := o.h2[key][:0]
+	for _, conn := range o.h2[key] {
+		if state := conn.State(); state.Closed || state.Closing {
+			continue
+		}
+		open = append(open, conn)
+
+		if found == nil && conn.CanTakeNewRequest() {
+			found = conn
+		}
+	}
+
+	if len(open) > 0 {
+		o.h2[key] = open
+	} else {
+		delete(o.h2, key)
+	}
+
+	return found
+}
+
+func (o *orderedPool) putH2(key orderedConnKey, conn *http2.ClientConn) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.h2 == nil {
+		o.h2 = map[orderedConnKey][]*http2.ClientConn{}
+	}
+	o.h2[key] = append(o.h2[key], conn)
+}
+
+// closeIdleConnections closes the idle connections, HTTP/2 ones once their requests are done.
+func (o *orderedPool) closeIdleConnections() {
+	o.mutex.Lock()
+	var idle []*orderedConn
+	for _, host := range o.hosts {
+		idle = append(idle, host.idle...)
+		host.idle = nil
+	}
+	o.idle = 0
+	for _, conn := range idle {
+		if conn.idleTimer != nil {
+			conn.idleTimer.Stop()
+		}
+	}
+	var h2 []*http2.ClientConn
+	for _, conns := range o.h2 {
+		h2 = append(h2, conns...)
+	}
+	o.h2 = nil
+	o.mutex.Unlock()
+
+	for _, conn := range idle {
+		o.remove(conn)
+	}
+	for _, conn := range h2 {
+		go conn.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,433 @@
+package http_runner
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+type headerOrderKey struct{}
+
+// orderedTransport sends requests carrying a header order over HTTP/1.1, writing the headers in
+// exactly that order; net/http sorts them. It also sends the https requests of a TLSHandshaker,
+// whose connections net/http can not speak HTTP/2 over, over HTTP/2 when the server picks h2.
+// Its connections are pooled per host and TLS handshaker with the limits and timeouts of
+// options, ExpectContinueTimeout aside: the body is sent right after the headers. Responses
+// compressed with a coding the request lists in Accept-Encoding are decoded. Other requests go
+// to base.
+type orderedTransport struct {
+	base      http.RoundTripper
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsConfig *tls.Config
+	// tlsHandshaker is used unless the request context carries a handshaker of its own
+	tlsHandshaker TLSHandshaker
+	options       TransportOptions
+	h2            *http2.Transport
+
+	pool orderedPool
+}
+
+func (o *orderedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	order, ordered := req.Context().Value(headerOrderKey{}).([]string)
+
+	key := o.connKey(req)
+	if !ordered && key.handshaker == nil {
+		return o.base.RoundTrip(req)
+	}
+
+	// like net/http, requests not asking for a coding get gzip
+	if !ordered && len(req.Header.Get("Accept-Encoding")) <= 0 && len(req.Header.Get("Range")) <= 0 && req.Method != http.MethodHead {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	for {
+		resp, reused, err := o.send(key, req, order)
+		if err == nil {
+			return resp, nil
+		}
+
+		// the server may have closed an idle connection just as it was reused
+		if !reused || !errors.Is(err, errStaleConn) {
+			return nil, err
+		}
+
+		var ok bool
+		if req, ok = rewindRequest(req); !ok {
+			return nil, err
+		}
+	}
+}
+
+// send sends req over an HTTP/2 connection of key or over an HTTP/1.1 connection of the pool,
+// a new connection becoming either as the server picks. It reports whether the connection was reused.
+func (o *orderedTransport) send(key orderedConnKey, req *http.Request, order []string) (*http.Response, bool, error) {
+	h2Conn := o.pool.getH2(key)
+	reused := h2Conn != nil
+
+	if h2Conn == nil {
+		conn, connReused, err := o.pool.get(req.Context(), key, o.options.MaxConnsPerHost, o.connect)
+		if err != nil {
+			closeRequestBody(req)
+			return nil, false, err
+		}
+
+		if connReused || negotiatedProtocol(conn.Conn) != http2.NextProtoTLS {
+			if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+				trace.GotConn(httptrace.GotConnInfo{Conn: conn.Conn, Reused: connReused, WasIdle: connReused})
+			}
+
+			resp, err := o.roundTrip(conn, req, order)
+			return resp, connReused, err
+		}
+
+		o.pool.release(key)
+		if h2Conn, err = o.h2.NewClientConn(conn.Conn); err != nil {
+			conn.Conn.Close()
+			closeRequestBody(req)
+			return nil, false, err
+		}
+		o.pool.putH2(key, h2Conn)
+	}
+
+	resp, err := h2Conn.RoundTrip(req)
+	if err != nil {
+		// a connection the server is closing fails the requests it can not take any more
+		if reused && req.Context().Err() == nil && !h2Conn.CanTakeNewRequest() {
+			err = fmt.Errorf("%w: %v", errStaleConn, err)
+		}
+
+		return nil, reused, err
+	}
+	decodeResponse(req, resp)
+
+	return resp, reused, nil
+}
+
+// connKey is the pool key of req: its scheme, address and, over https, TLS handshaker.
+func (o *orderedTransport) connKey(req *http.Request) orderedConnKey {
+	port := req.URL.Port()
+	if len(port) <= 0 {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	key := orderedConnKey{scheme: req.URL.Scheme, addr: net.JoinHostPort(req.URL.Hostname(), port)}
+	if key.scheme == "https" {
+		key.handshaker = o.tlsHandshaker
+		if contextHandshaker, ok := req.Context().Value(tlsHandshakerKey{}).(TLSHandshaker); ok {
+			key.handshaker = contextHandshaker
+		}
+	}
+
+	return key
+}
+
+func (o *orderedTransport) connect(ctx context.Context, key orderedConnKey) (net.Conn, error) {
+	conn, err := o.dial(ctx, "tcp", key.addr)
+	if err != nil || key.scheme != "https" {
+		return conn, err
+	}
+
+	if o.options.TLSHandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.options.TLSHandshakeTimeout)
+		defer cancel()
+	}
+
+	host, _, _ := net.SplitHostPort(key.addr)
+
+	return handshakeTLS(ctx, conn, key.handshaker, o.tlsConfig, host, []string{"http/1.1"})
+}
+
+// roundTrip sends req over conn. The connection goes back to the pool once the response body is
+// read to the end and closed, it is closed otherwise.
+func (o *orderedTransport) roundTrip(conn *orderedConn, req *http.Request, order []string) (*http.Response, error) {
+	ctx := req.Context()
+
+	// closing the connection is the only way to stop a blocked read or write
+	done := make(chan struct{})
+	var releaseOnce sync.Once
+	release := func(reusable bool) {
+		releaseOnce.Do(func() {
+			close(done)
+			if reusable && ctx.Err() == nil {
+				o.pool.put(conn, o.options)
+			} else {
+				o.pool.remove(conn)
+			}
+		})
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			release(false)
+		case <-done:
+		}
+	}()
+
+	read := conn.read
+	if err := writeOrderedRequest(conn.writer, req, order); err != nil {
+		release(false)
+		return nil, contextError(ctx, staleConnError(err, conn.read == read))
+	}
+
+	if o.options.ResponseHeaderTimeout > 0 {
+		conn.Conn.SetReadDeadline(time.Now().Add(o.options.ResponseHeaderTimeout))
+	}
+
+	for {
+		resp, err := http.ReadResponse(conn.reader, req)
+		if err != nil {
+			release(false)
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+				return nil, fmt.Errorf("timeout awaiting response headers: %w", err)
+			}
+
+			return nil, contextError(ctx, staleConnError(err, conn.read == read))
+		}
+
+		// skip informational responses such as 100 Continue
+		if resp.StatusCode >= 100 && resp.StatusCode < 200 && resp.StatusCode != http.StatusSwitchingProtocols {
+			continue
+		}
+
+		conn.Conn.SetReadDeadline(time.Time{})
+
+		reusable := !o.options.DisableKeepAlives && !resp.Close && !req.Close && resp.StatusCode != http.StatusSwitchingProtocols
+		if resp.Body == http.NoBody {
+			release(reusable)
+		} else {
+			resp.Body = &orderedBody{ReadCloser: resp.Body, release: release, reusable: reusable}
+			decodeResponse(req, resp)
+		}
+
+		return resp, nil
+	}
+}
+
+// writeOrderedRequest writes the head of req with its headers in order, Host first unless
+// order places it. Keys differing only in case are sent once, the spelling of order wins.
+// Headers not in order follow sorted by key.
+func writeOrderedRequest(writer *bufio.Writer, req *http.Request, order []string) error {
+	defer closeRequestBody(req)
+
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for key := range header {
+		if strings.EqualFold(key, "Host") || strings.EqualFold(key, "Content-Length") || strings.EqualFold(key, "Transfer-Encoding") {
+			delete(header, key)
+		}
+	}
+
+	host := req.Host
+	if len(host) <= 0 {
+		host = req.URL.Host
+	}
+	header["Host"] = []string{host}
+
+	chunked := false
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength <= 0 {
+		chunked = true
+		header["Transfer-Encoding"] = []string{"chunked"}
+	} else if req.ContentLength > 0 || req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		header["Content-Length"] = []string{strconv.FormatInt(req.ContentLength, 10)}
+	}
+
+	if _, err := fmt.Fprintf(writer, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI()); err != nil {
+		return err
+	}
+
+	hasHost := false
+	for _, key := range order {
+		if strings.EqualFold(key, "Host") {
+			hasHost = true
+		}
+	}
+	if !hasHost {
+		order = append([]string{"Host"}, order...)
+	}
+
+	written := map[string]bool{}
+	writeKey := func(key string) error {
+		if written[strings.ToLower(key)] {
+			return nil
+		}
+		written[strings.ToLower(key)] = true
+
+		values, ok := header[key]
+		if !ok {
+			// the key may be stored in another spelling, such as the canonical one
+			for storedKey, storedValues := range header {
+				if strings.EqualFold(storedKey, key) {
+					values = storedValues
+					break
+				}
+			}
+		}
+
+		for _, value := range values {
+			value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+			if _, err := fmt.Fprintf(writer, "%s: %s\r\n", key, value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, key := range order {
+		if err := writeKey(key); err != nil {
+			return err
+		}
+	}
+
+	remaining := make([]string, 0, len(header))
+	for key := range header {
+		remaining = append(remaining, key)
+	}
+	sort.Strings(remaining)
+
+	for _, key := range remaining {
+		if err := writeKey(key); err != nil {
+			return err
+		}
+	}
+
+	if _, err := writer.WriteString("\r\n"); err != nil {
+		return err
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		var body io.Writer = writer
+
+		var chunkedWriter io.WriteCloser
+		if chunked {
+			chunkedWriter = httputil.NewChunkedWriter(writer)
+			body = chunkedWriter
+		}
+
+		if _, err := io.Copy(body, req.Body); err != nil {
+			return err
+		}
+
+		if chunked {
+			if err := chunkedWriter.Close(); err != nil {
+				return err
+			}
+			if _, err := writer.WriteString("\r\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writer.Flush()
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// contextError prefers the error of a canceled context over the closed connection error it causes.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// errStaleConn marks the failure of a reused connection the server closed before it answered.
+var errStaleConn = errors.New("connection closed by the server")
+
+func staleConnError(err error, nothingRead bool) error {
+	if !nothingRead {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", errStaleConn, err)
+}
+
+// rewindRequest returns req ready to be sent again over a new connection, false when sending it
+// again may repeat its effect or its body can not be read again.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+	default:
+		if len(req.Header.Get("Idempotency-Key")) <= 0 {
+			return nil, false
+		}
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	rewound := req.Clone(req.Context())
+	rewound.Body = body
+
+	return rewound, true
+}
+
+// orderedBody releases the connection of an ordered request with the response body, back to the
+// pool when the body was read to the end.
+type orderedBody struct {
+	io.ReadCloser
+	release  func(reusable bool)
+	reusable bool
+	eof      bool
+}
+
+func (o *orderedBody) Read(p []byte) (int, error) {
+	n, err := o.ReadCloser.Read(p)
+	if err == io.EOF {
+		o.eof = true
+	}
+
+	return n, err
+}
+
+func (o *orderedBody) Close() error {
+	if !o.eof {
+		// closing the body reads what is left of it, closing the connection first spares that
+		o.release(false)
+		_ = o.ReadCloser.Close()
+
+		return nil
+	}
+
+	err := o.ReadCloser.Close()
+	o.release(o.reusable)
+
+	return err
+}
@@ -0,0 +1,153 @@
+package http_runner
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// BrowserProfile is the set of headers a browser sends when navigating to a page, in the order the
+// browser sends them. Only Chromium based browsers send sec-ch-ua client hints. The responses
+// compressed as Accept-Encoding asks are decoded, see orderedTransport.
+// TLSHandshaker sends the ClientHello of the browser, unless the runner sets WithTLSHandshaker.
+// Its ALPN offers h2 as the browser does, servers picking it are spoken to over HTTP/2, which
+// sends the headers in its own order.
+type BrowserProfile struct {
+	Name          string
+	Headers       Headers
+	TLSHandshaker TLSHandshaker
+}
+
+var (
+	ChromeDesktop = BrowserProfile{
+		Name: "chrome-desktop",
+		Headers: NewHeaders(
+			"sec-ch-ua", `"Chromium";v="130", "Google Chrome";v="130", "Not?A_Brand";v="99"`,
+			"sec-ch-ua-mobile", "?0",
+			"sec-ch-ua-platform", `"Windows"`,
+			"Upgrade-Insecure-Requests", "1",
+			"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36",
+			"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+			"Sec-Fetch-Site", "none",
+			"Sec-Fetch-Mode", "navigate",
+			"Sec-Fetch-User", "?1",
+			"Sec-Fetch-Dest", "document",
+			"Accept-Encoding", "gzip, deflate, br, zstd",
+			"Accept-Language", "en-US,en;q=0.9",
+			"Priority", "u=0, i",
+		),
+		TLSHandshaker: ChromeTLSHandshaker,
+	}
+	ChromeMobile = BrowserProfile{
+		Name: "chrome-mobile",
+		Headers: NewHeaders(
+			"sec-ch-ua", `"Chromium";v="130", "Google Chrome";v="130", "Not?A_Brand";v="99"`,
+			"sec-ch-ua-mobile", "?1",
+			"sec-ch-ua-platform", `"Android"`,
+			"Upgrade-Insecure-Requests", "1",
+			"User-Agent", "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Mobile Safari/537.36",
+			"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+			"Sec-Fetch-Site", "none",
+			"Sec-Fetch-Mode", "navigate",
+			"Sec-Fetch-User", "?1",
+			"Sec-Fetch-Dest", "document",
+			"Accept-Encoding", "gzip, deflate, br, zstd",
+			"Accept-Language", "en-US,en;q=0.9",
+			"Priority", "u=0, i",
+		),
+		TLSHandshaker: ChromeTLSHandshaker,
+	}
+	FirefoxDesktop = BrowserProfile{
+		Name: "firefox-desktop",
+		Headers: NewHeaders(
+			"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:132.0) Gecko/20100101 Firefox/132.0",
+			"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Accept-Language", "en-US,en;q=0.5",
+			"Accept-Encoding", "gzip, deflate, br, zstd",
+			"Upgrade-Insecure-Requests", "1",
+			"Sec-Fetch-Dest", "document",
+			"Sec-Fetch-Mode", "navigate",
+			"Sec-Fetch-Site", "none",
+			"Sec-Fetch-User", "?1",
+			"Priority", "u=0, i",
+		),
+		TLSHandshaker: FirefoxTLSHandshaker,
+	}
+	FirefoxMobile = BrowserProfile{
+		Name: "firefox-mobile",
+		Headers: NewHeaders(
+			"User-Agent", "Mozilla/5.0 (Android 14; Mobile; rv:132.0) Gecko/132.0 Firefox/132.0",
+			"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Accept-Language", "en-US,en;q=0.5",
+			"Accept-Encoding", "gzip, deflate, br, zstd",
+			"Upgrade-Insecure-Requests", "1",
+			"Sec-Fetch-Dest", "document",
+			"Sec-Fetch-Mode", "navigate",
+			"Sec-Fetch-Site", "none",
+			"Sec-Fetch-User", "?1",
+			"Priority", "u=0, i",
+		),
+		TLSHandshaker: FirefoxTLSHandshaker,
+	}
+	SafariDesktop = BrowserProfile{
+		Name: "safari-desktop",
+		Headers: NewHeaders(
+			"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Sec-Fetch-Site", "none",
+			"Sec-Fetch-Mode", "navigate",
+			"User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15",
+			"Accept-Language", "en-US,en;q=0.9",
+			"Sec-Fetch-Dest", "document",
+			"Accept-Encoding", "gzip, deflate, br",
+		),
+		TLSHandshaker: SafariTLSHandshaker,
+	}
+	SafariMobile = BrowserProfile{
+		Name: "safari-mobile",
+		Headers: NewHeaders(
+			"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Sec-Fetch-Site", "none",
+			"Sec-Fetch-Mode", "navigate",
+			"User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 18_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Mobile/15E148 Safari/604.1",
+			"Accept-Language", "en-US,en;q=0.9",
+			"Sec-Fetch-Dest", "document",
+			"Accept-Encoding", "gzip, deflate, br",
+		),
+		TLSHandshaker: IOSTLSHandshaker,
+	}
+)
+
+// BrowserProfiles are all the predefined profiles.
+var BrowserProfiles = []BrowserProfile{ChromeDesktop, ChromeMobile, FirefoxDesktop, FirefoxMobile, SafariDesktop, SafariMobile}
+
+// BrowserProfileByName finds a predefined profile by its name, such as "chrome-desktop".
+func BrowserProfileByName(name string) (BrowserProfile, bool) {
+	for _, profile := range BrowserProfiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, true
+		}
+	}
+
+	return BrowserProfile{}, false
+}
+
+// ProfileRotation decides how often a runner with several browser profiles changes its profile.
+type ProfileRotation int
+
+const (
+	// RotatePerRequest sends every request with the next profile.
+	RotatePerRequest ProfileRotation = iota + 1
+	// RotatePerSession gives every session made by WithCookieJar the next profile, so a session
+	// keeps looking like the same browser. Requests made without a session use the first profile.
+	RotatePerSession
+)
+
+// profileRotation hands out profiles in turn, it is shared by a runner and its sessions.
+type profileRotation struct {
+	profiles []BrowserProfile
+	rotation ProfileRotation
+	next     uint32
+}
+
+func (p *profileRotation) pick() BrowserProfile {
+	return p.profiles[(atomic.AddUint32(&p.next, 1)-1)%uint32(len(p.profiles))]
+}
@@ -0,0 +1,152 @@
+package http_runner
+
+import (
+	"compress/gzip"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestBrowserProfiles(t *testing.T) {
+	for _, profile := range BrowserProfiles {
+		if len(profile.Headers.Get("User-Agent")) <= 0 || len(profile.Headers.Get("Accept")) <= 0 || len(profile.Headers.Get("Sec-Fetch-Mode")) <= 0 {
+			t.Errorf("profile %v misses User-Agent, Accept or Sec-Fetch-Mode", profile.Name)
+		}
+
+		chromium := strings.Contains(profile.Headers.Get("User-Agent"), "Chrome/")
+		if hasClientHints := len(profile.Headers.Get("sec-ch-ua")) > 0; hasClientHints != chromium {
+			t.Errorf("profile %v sends sec-ch-ua %v, want %v", profile.Name, hasClientHints, chromium)
+		}
+
+		if found, ok := BrowserProfileByName(strings.ToUpper(profile.Name)); !ok || found.Name != profile.Name {
+			t.Errorf("BrowserProfileByName(%v) = %v, %v", profile.Name, found.Name, ok)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-user-agent", r.Header.Get("User-Agent"))
+		w.Header().Set("x-fetch-mode", r.Header.Get("Sec-Fetch-Mode"))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userAgent := func(runner IHttpRunner) string {
+		response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.Header().Get("x-fetch-mode"); got != "navigate" {
+			t.Errorf("Sec-Fetch-Mode = %v, want %v", got, "navigate")
+		}
+
+		return response.Header().Get("x-user-agent")
+	}
+
+	t.Run("TestBrowserProfiles-Profile", func(t *testing.T) {
+		runner, err := NewHttpRunner(directDialer, WithBrowserProfile(FirefoxDesktop))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := userAgent(runner), FirefoxDesktop.Headers.Get("User-Agent"); got != want {
+			t.Errorf("User-Agent = %v, want %v", got, want)
+		}
+
+		runner, err = NewHttpRunner(directDialer, WithBrowserProfile(FirefoxDesktop), WithUserAgent("test-agent"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := userAgent(runner); got != "test-agent" {
+			t.Errorf("User-Agent = %v, want %v", got, "test-agent")
+		}
+	})
+	t.Run("TestBrowserProfiles-RotatePerRequest", func(t *testing.T) {
+		runner, err := NewHttpRunner(directDialer, WithBrowserProfiles(RotatePerRequest, ChromeDesktop, SafariMobile))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, profile := range []BrowserProfile{ChromeDesktop, SafariMobile, ChromeDesktop} {
+			if got, want := userAgent(runner), profile.Headers.Get("User-Agent"); got != want {
+				t.Errorf("User-Agent = %v, want %v", got, want)
+			}
+		}
+	})
+	t.Run("TestBrowserProfiles-RotatePerSession", func(t *testing.T) {
+		runner, err := NewHttpRunner(directDialer, WithBrowserProfiles(RotatePerSession, ChromeMobile, FirefoxMobile))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		firstJar, err := NewCookieJar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secondJar, err := NewCookieJar()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first := runner.WithCookieJar(firstJar)
+		second := runner.WithCookieJar(secondJar)
+
+		for i := 0; i < 2; i++ {
+			if got, want := userAgent(first), ChromeMobile.Headers.Get("User-Agent"); got != want {
+				t.Errorf("first session User-Agent = %v, want %v", got, want)
+			}
+			if got, want := userAgent(second), FirefoxMobile.Headers.Get("User-Agent"); got != want {
+				t.Errorf("second session User-Agent = %v, want %v", got, want)
+			}
+		}
+	})
+	t.Run("TestBrowserProfiles-Encoding", func(t *testing.T) {
+		var opened int32
+		gzipServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || r.Header.Get("Priority") != "u=0, i" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			writer.Write([]byte("decoded"))
+			writer.Close()
+		}))
+		gzipServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&opened, 1)
+			}
+		}
+		gzipServer.Start()
+		defer gzipServer.Close()
+
+		runner, err := NewHttpRunner(directDialer, WithBrowserProfile(ChromeDesktop))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 5; i++ {
+			response, err := runner.GetHtml(NewHtmlRequestOptions(gzipServer.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode() != http.StatusOK || response.String() != "decoded" {
+				t.Fatalf("response = %v %q, want %v %q", response.StatusCode(), response.String(), http.StatusOK, "decoded")
+			}
+		}
+
+		if got := atomic.LoadInt32(&opened); got != 1 {
+			t.Errorf("opened %v connections, want 1 reused", got)
+		}
+	})
+}
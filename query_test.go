@@ -0,0 +1,66 @@
+package http_runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestAddQueryParams(t *testing.T) {
+	tests := []struct {
+		requestUrl string
+		params     url.Values
+		want       string
+	}{
+		{requestUrl: "https://example.com/path", params: url.Values{"q": {"a b&c"}}, want: "https://example.com/path?q=a+b%26c"},
+		{requestUrl: "https://example.com/path?x=1", params: url.Values{"q": {"1", "2"}}, want: "https://example.com/path?x=1&q=1&q=2"},
+		{requestUrl: "https://example.com/path?", params: url.Values{"q": {"é"}}, want: "https://example.com/path?q=%C3%A9"},
+		{requestUrl: "https://example.com/path?b=%2F#top", params: url.Values{"a": {"/"}}, want: "https://example.com/path?b=%2F&a=%2F#top"},
+		{requestUrl: "https://example.com/path?x=1", params: url.Values{}, want: "https://example.com/path?x=1"},
+	}
+
+	for _, tt := range tests {
+		got, err := addQueryParams(tt.requestUrl, tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("addQueryParams(%v, %v) = %v, want %v", tt.requestUrl, tt.params, got, tt.want)
+		}
+	}
+
+	if _, err := addQueryParams("http://[::1", url.Values{"q": {"1"}}); err == nil {
+		t.Error("addQueryParams() error = nil, want an url error")
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-query", r.URL.RawQuery)
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewHttpRunner(directDialer, WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htmlRequest := NewHtmlRequestOptions("/search?page=2")
+	htmlRequest.SetQueryParams(url.Values{"tag": {"go", "c++"}})
+
+	response, err := runner.GetHtml(htmlRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := response.Header().Get("x-query"), "page=2&tag=go&tag=c%2B%2B"; got != want {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package http_runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RedirectPolicy decides which redirects a runner follows. A policy set by WithRedirectPolicy
+// applies to every method alike, without one the JSON methods use DefaultJsonRedirectPolicy
+// and the others DefaultRedirectPolicy. A request's FollowRedirectOption overrides Follow, the
+// remaining rules still apply.
+// A redirect refused by SameHostOnly or HttpsOnly is not an error: the redirect response
+// itself is returned. Going over MaxHops is an error.
+type RedirectPolicy struct {
+	Follow       bool
+	MaxHops      int  // zero means 10
+	SameHostOnly bool // only follow redirects to the host of the original request
+	HttpsOnly    bool // only follow redirects to https urls
+}
+
+// DefaultRedirectPolicy follows up to 10 redirects, like net/http does.
+var DefaultRedirectPolicy = RedirectPolicy{
+	Follow:  true,
+	MaxHops: 10,
+}
+
+// DefaultJsonRedirectPolicy follows no redirects, the JSON methods have never followed them by default.
+var DefaultJsonRedirectPolicy = RedirectPolicy{
+	Follow:  false,
+	MaxHops: 10,
+}
+
+func (r RedirectPolicy) maxHops() int {
+	if r.MaxHops <= 0 {
+		return 10
+	}
+
+	return r.MaxHops
+}
+
+type redirectTraceKey struct{}
+
+// redirectTrace carries the redirect policy of one request attempt and records the
+// redirects it followed.
+type redirectTrace struct {
+	policy RedirectPolicy
+	chain  []*url.URL
+}
+
+func withRedirectTrace(ctx context.Context, policy RedirectPolicy) context.Context {
+	return context.WithValue(ctx, redirectTraceKey{}, &redirectTrace{policy: policy})
+}
+
+// contextRedirectPolicy is installed once on the shared client; the policy of a
+// particular request is carried in its context instead of on the client.
+func contextRedirectPolicy() resty.RedirectPolicy {
+	return resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		trace, ok := req.Context().Value(redirectTraceKey{}).(*redirectTrace)
+		if !ok {
+			trace = &redirectTrace{policy: DefaultRedirectPolicy}
+		}
+
+		policy := trace.policy
+
+		if !policy.Follow {
+			return http.ErrUseLastResponse // disable redirect
+		}
+		if policy.SameHostOnly && req.URL.Host != via[0].URL.Host {
+			return http.ErrUseLastResponse
+		}
+		if policy.HttpsOnly && req.URL.Scheme != "https" {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= policy.maxHops() {
+			return fmt.Errorf("stopped after %d redirects", policy.maxHops())
+		}
+
+		trace.chain = append(trace.chain, req.URL)
+
+		return nil
+	})
+}
+
+// RedirectChain returns the urls a request was redirected to, in the order they were
+// followed. It is empty when no redirect was followed.
+func RedirectChain(response *resty.Response) []*url.URL {
+	if response == nil || response.Request == nil {
+		return nil
+	}
+
+	if trace, ok := response.Request.Context().Value(redirectTraceKey{}).(*redirectTrace); ok {
+		return trace.chain
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package http_runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer otherServer.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusFound)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherServer.URL, http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	follow, noFollow := true, false
+
+	tests := []struct {
+		name           string
+		html           bool
+		policy         *RedirectPolicy
+		followRedirect *bool
+		path           string
+		wantStatus     int
+		wantChain      []string
+		wantErr        bool
+	}{
+		{name: "Default", path: "/a", wantStatus: http.StatusFound},
+		{name: "DefaultHtml", html: true, path: "/a", wantStatus: http.StatusOK, wantChain: []string{"/b", "/c"}},
+		{name: "RunnerFollow", policy: &RedirectPolicy{Follow: true}, path: "/a", wantStatus: http.StatusOK, wantChain: []string{"/b", "/c"}},
+		{name: "RequestFollow", followRedirect: &follow, path: "/a", wantStatus: http.StatusOK, wantChain: []string{"/b", "/c"}},
+		{name: "RequestNoFollow", followRedirect: &noFollow, path: "/a", wantStatus: http.StatusFound},
+		{name: "RunnerNoFollow", policy: &RedirectPolicy{}, path: "/a", wantStatus: http.StatusFound},
+		{name: "RunnerNoFollowRequestFollow", policy: &RedirectPolicy{}, followRedirect: &follow, path: "/a", wantStatus: http.StatusOK, wantChain: []string{"/b", "/c"}},
+		{name: "MaxHops", policy: &RedirectPolicy{Follow: true, MaxHops: 1}, path: "/a", wantErr: true},
+		{name: "SameHostOnly", policy: &RedirectPolicy{Follow: true, SameHostOnly: true}, path: "/other", wantStatus: http.StatusFound},
+		{name: "OtherHost", policy: &RedirectPolicy{Follow: true}, path: "/other", wantStatus: http.StatusOK, wantChain: []string{otherServer.URL}},
+		{name: "HttpsOnly", policy: &RedirectPolicy{Follow: true, HttpsOnly: true}, path: "/a", wantStatus: http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestRedirectPolicy-"+tt.name, func(t *testing.T) {
+			runner := directHttpRunner
+			if tt.policy != nil {
+				runner = directHttpRunner.WithRedirectPolicy(*tt.policy)
+			}
+
+			var requestOptions IBaseRequest = NewJsonRequestOptions(server.URL + tt.path)
+			if tt.html {
+				requestOptions = NewHtmlRequestOptions(server.URL + tt.path)
+			}
+			if tt.followRedirect != nil {
+				requestOptions.SetFollowRedirectOption(*tt.followRedirect)
+			}
+
+			response, err := runner.Do(http.MethodGet, requestOptions)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("runner.Do() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := response.StatusCode(); got != tt.wantStatus {
+				t.Errorf("response.StatusCode() = %v, want %v", got, tt.wantStatus)
+			}
+
+			chain := RedirectChain(response)
+			if len(chain) != len(tt.wantChain) {
+				t.Fatalf("RedirectChain() = %v, want %v", chain, tt.wantChain)
+			}
+			for i, hop := range chain {
+				if !strings.HasSuffix(strings.TrimSuffix(hop.String(), "/"), tt.wantChain[i]) {
+					t.Fatalf("RedirectChain() = %v, want %v", chain, tt.wantChain)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package http_runner
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryPolicy decides which failed attempts are retried and how long to wait in between.
+// The number of retries is still set by WithRetryCount or the request retry option.
+type RetryPolicy struct {
+	MinBackoff time.Duration // wait before the first retry, doubled for every next one
+	MaxBackoff time.Duration // upper bound of the exponential backoff
+	Jitter     float64       // fraction (0..1) of the backoff randomly taken off each wait
+
+	RetryOnStatus []int                // response status codes that are retried
+	RetryOnError  func(err error) bool // transport errors that are retried, nil retries them all
+
+	// RespectRetryAfter waits as long as the Retry-After header of a retried response asks,
+	// at most MaxRetryAfter when it is set.
+	RespectRetryAfter bool
+	MaxRetryAfter     time.Duration
+
+	// RetryNonIdempotent allows retrying POST, PATCH and custom methods after the request
+	// may have reached the server. Without it they are only retried when the connection
+	// could not be established or when the request carries an Idempotency-Key header.
+	RetryNonIdempotent bool
+
+	// BeforeAttempt is called before every attempt, attempt counts from 1, response and err
+	// are the outcome of the previous attempt and are nil before the first one.
+	BeforeAttempt func(attempt int, response *resty.Response, err error)
+}
+
+// DefaultRetryPolicy retries transport errors of idempotent requests with an exponential
+// backoff from 100ms up to 2s.
+var DefaultRetryPolicy = RetryPolicy{
+	MinBackoff:        time.Millisecond * 100,
+	MaxBackoff:        time.Second * 2,
+	Jitter:            0.2,
+	RespectRetryAfter: true,
+}
+
+func (r RetryPolicy) shouldRetry(method string, request *resty.Request, response *resty.Response, err error) bool {
+	if err != nil {
+		if r.RetryOnError != nil && !r.RetryOnError(err) {
+			return false
+		}
+
+		return isDialError(err) || r.mayRepeat(method, request)
+	}
+
+	if response == nil || !r.retryOnStatus(response.StatusCode()) {
+		return false
+	}
+
+	return r.mayRepeat(method, request)
+}
+
+func (r RetryPolicy) retryOnStatus(statusCode int) bool {
+	for _, retryStatus := range r.RetryOnStatus {
+		if retryStatus == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r RetryPolicy) mayRepeat(method string, request *resty.Request) bool {
+	if r.RetryNonIdempotent {
+		return true
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return request != nil && len(request.Header.Get("Idempotency-Key")) > 0
+}
+
+// backoff returns how long to wait before retry number retry (counting from 0).
+func (r RetryPolicy) backoff(retry int, response *resty.Response) time.Duration {
+	if r.RespectRetryAfter && response != nil {
+		if retryAfter, ok := parseRetryAfter(response.Header().Get("Retry-After")); ok {
+			if r.MaxRetryAfter > 0 && retryAfter > r.MaxRetryAfter {
+				retryAfter = r.MaxRetryAfter
+			}
+
+			return retryAfter
+		}
+	}
+
+	waitTime := r.MinBackoff << retry
+	if waitTime > r.MaxBackoff || waitTime <= 0 {
+		waitTime = r.MaxBackoff
+	}
+
+	if r.Jitter > 0 && waitTime > 0 {
+		waitTime -= time.Duration(rand.Float64() * r.Jitter * float64(waitTime))
+	}
+
+	return waitTime
+}
+
+// parseRetryAfter parses both the delay-seconds and the http-date form of Retry-After.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if waitTime := time.Until(date); waitTime > 0 {
+			return waitTime, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// isDialError reports whether err happened while connecting, before anything was sent. That
+// covers the errors of proxies refusing the connection, which are no *net.OpError.
+func isDialError(err error) bool {
+	var dialErr *dialError
+	if errors.As(err, &dialErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// requestDefaults are the runner-level values used for options a request leaves unset.
+type requestDefaults struct {
+	retryCount     int
+	retryPolicy    RetryPolicy
+	timeout        time.Duration
+	redirectPolicy RedirectPolicy
+	cookieJar      http.CookieJar
+	baseUrl        string
+	progress       ProgressFunc
+	rateLimiter    *rateLimiter
+	errorOnStatus  bool
+	middlewares    []Middleware
+}
+
+// executeRequest runs a request built by buildRequest, retrying it as the retry policy says.
+// Retry, timeout and redirect settings apply to this request only, so the shared client
+// is never mutated and a runner can be used from many goroutines.
+// The request is rebuilt for every attempt.
+func executeRequest(ctx context.Context, method string, requestOptions IBaseRequest, defaults requestDefaults, buildRequest func(ctx context.Context) (*resty.Request, error)) (*resty.Response, error) {
+	return executeRequestWithHandler(ctx, method, requestOptions, defaults, buildRequest, nil)
+}
+
+// executeRequestWithHandler is executeRequest with handleResponse called on the response of
+// every attempt before the attempt is over, an error it returns fails the attempt.
+func executeRequestWithHandler(ctx context.Context, method string, requestOptions IBaseRequest, defaults requestDefaults, buildRequest func(ctx context.Context) (*resty.Request, error), handleResponse func(response *resty.Response) error) (*resty.Response, error) {
+	retryCount := defaults.retryCount
+	if requestOptions.IsRetryOptionSet() {
+		retryCount = requestOptions.RetryOption()
+	}
+	retryPolicy := defaults.retryPolicy
+	if requestOptions.IsRetryPolicyOptionSet() {
+		retryPolicy = requestOptions.RetryPolicyOption()
+	}
+	timeout := defaults.timeout
+	if requestOptions.IsTimeoutOptionSet() {
+		timeout = requestOptions.TimeoutOption()
+	}
+	requestUrl := resolveRequestUrl(defaults.baseUrl, requestOptions.Url())
+	if requestOptions.IsQueryParamsSet() {
+		var err error
+		if requestUrl, err = addQueryParams(requestUrl, requestOptions.QueryParams()); err != nil {
+			return nil, err
+		}
+	}
+
+	redirectPolicy := defaults.redirectPolicy
+	if requestOptions.IsFollowRedirectOptionSet() {
+		redirectPolicy.Follow = requestOptions.FollowRedirectOption()
+	}
+
+	errorOnStatus := defaults.errorOnStatus
+	if requestOptions.IsErrorOnStatusOptionSet() {
+		errorOnStatus = requestOptions.ErrorOnStatusOption()
+	}
+
+	if defaults.cookieJar != nil {
+		ctx = context.WithValue(ctx, cookieJarKey{}, defaults.cookieJar)
+	}
+
+	attempts := new(int)
+	ctx = context.WithValue(ctx, attemptsKey{}, attempts)
+
+	var (
+		response *resty.Response
+		err      error
+	)
+
+	for attempt := 0; ; attempt++ {
+		if retryPolicy.BeforeAttempt != nil {
+			retryPolicy.BeforeAttempt(attempt+1, response, err)
+		}
+
+		*attempts = attempt + 1
+
+		var request *resty.Request
+		request, response, err = executeAttempt(withRedirectTrace(ctx, redirectPolicy), method, requestUrl, timeout, defaults.middlewares, buildRequest, handleResponse)
+		// no request means it could not be built, which another attempt would not change
+		if request == nil || attempt >= retryCount || ctx.Err() != nil || !retryPolicy.shouldRetry(method, request, response, err) {
+			if err == nil && errorOnStatus && response.StatusCode() >= http.StatusBadRequest {
+				return response, newHttpError(response)
+			}
+
+			return response, err
+		}
+
+		select {
+		case <-time.After(retryPolicy.backoff(attempt, response)):
+		case <-ctx.Done():
+			return response, ctx.Err()
+		}
+	}
+}
+
+func executeAttempt(ctx context.Context, method, url string, timeout time.Duration, middlewares []Middleware, buildRequest func(ctx context.Context) (*resty.Request, error), handleResponse func(response *resty.Response) error) (*resty.Request, *resty.Response, error) {
+	// the attempt context is always canceled once the attempt is over, which also stops
+	// the writers of streamed bodies the transport did not consume
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	request, err := buildRequest(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := sendWithMiddlewares(request, method, url, middlewares, handleResponse)
+
+	return request, response, err
+}
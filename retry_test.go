@@ -0,0 +1,263 @@
+package http_runner
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+	"github.com/go-resty/resty/v2"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var hits int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1)%3 != 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retryPolicy := RetryPolicy{
+		MinBackoff:        time.Millisecond,
+		MaxBackoff:        time.Millisecond * 10,
+		RetryOnStatus:     []int{http.StatusServiceUnavailable},
+		RespectRetryAfter: true,
+		MaxRetryAfter:     time.Millisecond * 50,
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer, WithRetryCount(3), WithRetryPolicy(retryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		send         func() (*resty.Response, error)
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{
+			name:         "Idempotent",
+			send:         func() (*resty.Response, error) { return directHttpRunner.GetJson(NewJsonRequestOptions(server.URL)) },
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name:         "NonIdempotent",
+			send:         func() (*resty.Response, error) { return directHttpRunner.PostJson(NewJsonRequestOptions(server.URL)) },
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 1,
+		},
+		{
+			name: "IdempotencyKey",
+			send: func() (*resty.Response, error) {
+				jsonRequest := NewJsonRequestOptions(server.URL)
+				jsonRequest.SetHeaders(map[string]string{"Idempotency-Key": "test"})
+
+				return directHttpRunner.PostJson(jsonRequest)
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name: "RetryNonIdempotent",
+			send: func() (*resty.Response, error) {
+				requestRetryPolicy := retryPolicy
+				requestRetryPolicy.RetryNonIdempotent = true
+
+				jsonRequest := NewJsonRequestOptions(server.URL)
+				jsonRequest.SetRetryPolicyOption(requestRetryPolicy)
+
+				return directHttpRunner.PostJson(jsonRequest)
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name: "RetryCount",
+			send: func() (*resty.Response, error) {
+				jsonRequest := NewJsonRequestOptions(server.URL)
+				jsonRequest.SetRetryOption(1)
+
+				return directHttpRunner.GetJson(jsonRequest)
+			},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 2,
+		},
+		{
+			name: "DefaultPolicy",
+			send: func() (*resty.Response, error) {
+				jsonRequest := NewJsonRequestOptions(server.URL)
+				jsonRequest.SetRetryPolicyOption(DefaultRetryPolicy)
+
+				return directHttpRunner.GetJson(jsonRequest)
+			},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestRetryPolicy-"+tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+
+			startedAt := time.Now()
+
+			response, err := tt.send()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.StatusCode(); got != tt.wantStatus {
+				t.Errorf("response.StatusCode() = %v, want %v", got, tt.wantStatus)
+			}
+			if got := atomic.LoadInt32(&hits); got != tt.wantAttempts {
+				t.Errorf("attempts = %v, want %v", got, tt.wantAttempts)
+			}
+			if elapsed := time.Since(startedAt); elapsed > time.Second {
+				t.Errorf("retries took %v, want MaxRetryAfter to cap the Retry-After wait", elapsed)
+			}
+		})
+	}
+	t.Run("TestRetryPolicy-BeforeAttempt", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+
+		var attempts []int
+		var statusCodes []int
+
+		requestRetryPolicy := retryPolicy
+		requestRetryPolicy.BeforeAttempt = func(attempt int, response *resty.Response, err error) {
+			attempts = append(attempts, attempt)
+			if response != nil {
+				statusCodes = append(statusCodes, response.StatusCode())
+			}
+		}
+
+		jsonRequest := NewJsonRequestOptions(server.URL)
+		jsonRequest.SetRetryPolicyOption(requestRetryPolicy)
+
+		if _, err := directHttpRunner.GetJson(jsonRequest); err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+			t.Errorf("BeforeAttempt attempts = %v, want [1 2 3]", attempts)
+		}
+		if len(statusCodes) != 2 || statusCodes[0] != http.StatusServiceUnavailable {
+			t.Errorf("BeforeAttempt previous status codes = %v, want [503 503]", statusCodes)
+		}
+	})
+}
+
+func TestRetryPolicyDialError(t *testing.T) {
+	var hits int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the forwarder fails like a proxy refusing the CONNECT, with no *net.OpError
+	forwarder := &testForwarder{addr: "refusing", failing: 1}
+
+	retryPolicy := RetryPolicy{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		BeforeAttempt: func(attempt int, response *resty.Response, err error) {
+			if attempt > 1 {
+				atomic.StoreInt32(&forwarder.failing, 0)
+			}
+		},
+	}
+
+	runner, err := NewProxyHttpRunner(directDialer, WithRetryCount(1), WithRetryPolicy(retryPolicy), WithForwarders(Forwarder{Dialer: forwarder}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonRequest := NewJsonRequestOptions(server.URL)
+	jsonRequest.SetForwarderIndex(0)
+
+	// nothing reached the target, so even a POST is retried
+	if _, err := runner.PostJson(jsonRequest); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&forwarder.dials); got != 2 {
+		t.Errorf("dials = %v, want 2", got)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("hits = %v, want 1", got)
+	}
+}
+
+func TestRetryPolicyBuildError(t *testing.T) {
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts int32
+	retryPolicy := DefaultRetryPolicy
+	retryPolicy.BeforeAttempt = func(attempt int, response *resty.Response, err error) {
+		atomic.AddInt32(&attempts, 1)
+	}
+
+	runner, err := NewProxyHttpRunner(directDialer, WithRetryCount(3), WithRetryPolicy(retryPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htmlRequest := NewHtmlRequestOptions("http://127.0.0.1:1")
+	htmlRequest.SetForwarder("missing")
+
+	// a request that can not be built fails at once, without backoff
+	if _, err := runner.GetHtml(htmlRequest); !errors.Is(err, ErrUnknownForwarder) {
+		t.Errorf("error = %v, want %v", err, ErrUnknownForwarder)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("attempts = %v, want 1", got)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	retryPolicy := RetryPolicy{
+		MinBackoff: time.Millisecond * 100,
+		MaxBackoff: time.Second,
+		Jitter:     0.5,
+	}
+
+	for retry, want := range []time.Duration{time.Millisecond * 100, time.Millisecond * 200, time.Millisecond * 400, time.Millisecond * 800, time.Second, time.Second} {
+		for i := 0; i < 20; i++ {
+			if got := retryPolicy.backoff(retry, nil); got > want || got < want/2 {
+				t.Fatalf("backoff(%v) = %v, want between %v and %v", retry, got, want/2, want)
+			}
+		}
+	}
+
+	for value, want := range map[string]time.Duration{"": -1, "3": time.Second * 3, "-1": -1, "soon": -1, "Mon, 02 Jan 2006 15:04:05 GMT": 0} {
+		got, ok := parseRetryAfter(value)
+		if want < 0 && ok {
+			t.Errorf("parseRetryAfter(%q) = %v, want no value", value, got)
+		}
+		if want >= 0 && (!ok || got != want) {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
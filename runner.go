@@ -0,0 +1,461 @@
+package http_runner
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nadoo/glider/rule"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// httpRunner is the request pipeline shared by every runner, DirectHttpRunner and
+// ProxyHttpRunner only differ in their defaults.
+type httpRunner struct {
+	defHeaders    Headers
+	orderHeaders  bool
+	userAgent     string
+	profiles      *profileRotation
+	tlsHandshaker TLSHandshaker
+	// profileTLS is the handshaker of the profile of the runner or session
+	profileTLS     TLSHandshaker
+	retryCount     int
+	retryPolicy    RetryPolicy
+	timeout        time.Duration
+	redirectPolicy RedirectPolicy
+	// jsonRedirectPolicy is the redirect policy of the JSON methods
+	jsonRedirectPolicy RedirectPolicy
+	cookieJar          http.CookieJar
+	baseUrl            string
+	progress           ProgressFunc
+	rateLimiter        *rateLimiter
+	errorOnStatus      bool
+	middlewares        []Middleware
+	client             *resty.Client
+	transports         *forwarderTransport
+}
+
+func newHttpRunner(dialer *rule.Proxy, config runnerConfig) (*httpRunner, error) {
+	// CREATE TRANSPORT FOR HTTP
+	newStack := func(dial dialFunc) (*transportStack, error) {
+		return newTransportStack(config, dial)
+	}
+
+	base, err := newStack(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialContext(ctx, func(network, addr string) (net.Conn, error) {
+			return dialer.NextDialer(addr).Dial(network, addr)
+		}, network, addr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transports := &forwarderTransport{
+		dialer:     dialer,
+		forwarders: config.forwarders,
+		newStack:   newStack,
+		base:       base,
+	}
+	if len(config.banRules) > 0 || len(config.outcomeClassifiers) > 0 {
+		transports.feedback = &outcomeFeedback{}
+		for _, banRule := range config.banRules {
+			transports.feedback.classifiers = append(transports.feedback.classifiers, banRule.Classifier())
+		}
+		transports.feedback.classifiers = append(transports.feedback.classifiers, config.outcomeClassifiers...)
+	}
+	// CREATE TRANSPORT FOR HTTP
+
+	// CREATE A RESTY CLIENT
+	client := resty.New()
+	client.SetTransport(&cookieJarTransport{base: &requestBodyTransport{base: transports}})
+	client.SetCookieJar(nil) // cookies are kept by cookieJarTransport, in the jar of the runner or of a session
+	client.SetDisableWarn(true)
+	client.SetRedirectPolicy(contextRedirectPolicy())
+
+	if config.logger != nil {
+		client.SetLogger(config.logger)
+	} else if !log.IsLevelEnabled(log.TraceLevel) {
+		restyLogger := log.New()
+		restyLogger.SetOutput(io.Discard)
+
+		client.SetLogger(restyLogger)
+	}
+
+	headers := HeadersFromMap(config.headers)
+	if config.orderedHeaders != nil {
+		headers = config.orderedHeaders.Clone()
+	}
+	var profileTLS TLSHandshaker
+	if config.profiles != nil {
+		headers = config.profiles.profiles[0].Headers
+		profileTLS = config.profiles.profiles[0].TLSHandshaker
+	}
+	if len(config.userAgent) > 0 {
+		headers = headers.Set("User-Agent", config.userAgent)
+	}
+
+	// like resty.New, the runner keeps cookies unless WithCookieJar replaces its jar
+	cookieJar := config.cookieJar
+	if !config.cookieJarSet {
+		if cookieJar, err = NewCookieJar(); err != nil {
+			return nil, err
+		}
+	}
+
+	runner := &httpRunner{
+		defHeaders:         headers,
+		orderHeaders:       config.orderedHeaders != nil || config.profiles != nil,
+		userAgent:          config.userAgent,
+		profiles:           config.profiles,
+		tlsHandshaker:      config.tlsHandshaker,
+		profileTLS:         profileTLS,
+		retryCount:         config.retryCount,
+		retryPolicy:        config.retryPolicy,
+		timeout:            config.timeout,
+		redirectPolicy:     config.redirectPolicy,
+		jsonRedirectPolicy: config.jsonRedirectPolicy,
+		cookieJar:          cookieJar,
+		baseUrl:            config.baseUrl,
+		progress:           config.progress,
+		rateLimiter:        newRateLimiter(config.rateLimit),
+		errorOnStatus:      config.errorOnStatus,
+		middlewares:        config.middlewares,
+		client:             client,
+		transports:         transports,
+	}
+	// CREATE A RESTY CLIENT
+
+	return runner, nil
+}
+
+// Close closes the idle connections of the runner, and so of all its sessions. Connections in
+// use are closed once their response is read. The runner can still be used afterwards.
+func (h *httpRunner) Close() error {
+	h.transports.closeIdleConnections()
+
+	return nil
+}
+
+func (h *httpRunner) ReleaseSticky(key string) {
+	h.transports.releaseSticky(key)
+}
+
+func (h *httpRunner) requestDefaults() requestDefaults {
+	return requestDefaults{
+		retryCount:     h.retryCount,
+		retryPolicy:    h.retryPolicy,
+		timeout:        h.timeout,
+		redirectPolicy: h.redirectPolicy,
+		cookieJar:      h.cookieJar,
+		baseUrl:        h.baseUrl,
+		progress:       h.progress,
+		rateLimiter:    h.rateLimiter,
+		errorOnStatus:  h.errorOnStatus,
+		middlewares:    h.middlewares,
+	}
+}
+
+// withCookieJar returns a session of the runner keeping its cookies in jar, none when jar is nil.
+// Sessions of a profile rotated per session get the next profile.
+func (h *httpRunner) withCookieJar(jar http.CookieJar) *httpRunner {
+	session := *h
+	session.cookieJar = jar
+
+	if h.profiles != nil && h.profiles.rotation == RotatePerSession {
+		profile := h.profiles.pick()
+		session.defHeaders = h.profileHeaders(profile)
+		session.profileTLS = profile.TLSHandshaker
+	}
+
+	return &session
+}
+
+func (h *httpRunner) profileHeaders(profile BrowserProfile) Headers {
+	if len(h.userAgent) > 0 {
+		return profile.Headers.Set("User-Agent", h.userAgent)
+	}
+
+	return profile.Headers
+}
+
+// withRedirectPolicy returns a copy of the runner following redirects according to policy, for
+// every method.
+func (h *httpRunner) withRedirectPolicy(policy RedirectPolicy) *httpRunner {
+	runner := *h
+	runner.redirectPolicy = policy
+	runner.jsonRedirectPolicy = policy
+
+	return &runner
+}
+
+func (h *httpRunner) WithCookieJar(jar http.CookieJar) IHttpRunner {
+	return h.withCookieJar(jar)
+}
+
+func (h *httpRunner) WithRedirectPolicy(policy RedirectPolicy) IHttpRunner {
+	return h.withRedirectPolicy(policy)
+}
+
+func (h *httpRunner) GetJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.GetJsonWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) GetJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doJson(ctx, resty.MethodGet, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) GetHtml(requestOptions IHtmlRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.GetHtmlWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) GetHtmlWithContext(ctx context.Context, requestOptions IHtmlRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doHtml(ctx, resty.MethodGet, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) GetFile(requestOptions IFileRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.GetFileWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) GetFileWithContext(ctx context.Context, requestOptions IFileRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doFile(ctx, resty.MethodGet, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) PostJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.PostJsonWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) PostJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doJson(ctx, resty.MethodPost, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) PutJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.PutJsonWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) PutJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doJson(ctx, resty.MethodPut, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) PatchJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.PatchJsonWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) PatchJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doJson(ctx, resty.MethodPatch, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) DeleteJson(requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.DeleteJsonWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) DeleteJsonWithContext(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doJson(ctx, resty.MethodDelete, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) PostForm(requestOptions IFormRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.PostFormWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) PostFormWithContext(ctx context.Context, requestOptions IFormRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.doForm(ctx, resty.MethodPost, requestOptions, cookieJar)
+}
+
+func (h *httpRunner) Head(requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.HeadWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) HeadWithContext(ctx context.Context, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.DoWithContext(ctx, resty.MethodHead, requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) Options(requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.OptionsWithContext(context.Background(), requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) OptionsWithContext(ctx context.Context, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.DoWithContext(ctx, resty.MethodOptions, requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) Do(method string, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	return h.DoWithContext(context.Background(), method, requestOptions, cookieJar...)
+}
+
+func (h *httpRunner) DoWithContext(ctx context.Context, method string, requestOptions IBaseRequest, cookieJar ...*http.Cookie) (*resty.Response, error) {
+	switch requestOptions := requestOptions.(type) {
+	case IJsonRequestOptions:
+		return h.doJson(ctx, method, requestOptions, cookieJar)
+	case IFormRequestOptions:
+		return h.doForm(ctx, method, requestOptions, cookieJar)
+	case IFileRequestOptions:
+		return h.doFile(ctx, method, requestOptions, cookieJar)
+	case IHtmlRequestOptions:
+		return h.doHtml(ctx, method, requestOptions, cookieJar)
+	default:
+		return h.doBase(ctx, method, requestOptions, cookieJar)
+	}
+}
+
+func (h *httpRunner) doJson(ctx context.Context, method string, requestOptions IJsonRequestOptions, cookieJar []*http.Cookie) (*resty.Response, error) {
+	defaults := h.requestDefaults()
+	defaults.redirectPolicy = h.jsonRedirectPolicy
+
+	return executeRequest(ctx, method, requestOptions, defaults, func(ctx context.Context) (*resty.Request, error) {
+		request, err := h.newRequest(ctx, requestOptions, cookieJar)
+		if err != nil {
+			return nil, err
+		}
+
+		// requests without a value, such as Head, send no Content-Type
+		if requestOptions.IsValueSet() {
+			request.SetBody(requestOptions.Value())
+
+			if len(request.Header.Get("Content-Type")) <= 0 {
+				request.Header.Set("Content-Type", "application/json")
+			}
+		}
+
+		return request, nil
+	})
+}
+
+func (h *httpRunner) doHtml(ctx context.Context, method string, requestOptions IHtmlRequestOptions, cookieJar []*http.Cookie) (*resty.Response, error) {
+	return executeRequest(ctx, method, requestOptions, h.requestDefaults(), func(ctx context.Context) (*resty.Request, error) {
+		request, err := h.newRequest(ctx, requestOptions, cookieJar)
+		if err != nil {
+			return nil, err
+		}
+
+		if requestOptions.IsValueSet() {
+			request.SetBody(requestOptions.Value())
+		}
+
+		return request, nil
+	})
+}
+
+func (h *httpRunner) doFile(ctx context.Context, method string, requestOptions IFileRequestOptions, cookieJar []*http.Cookie) (*resty.Response, error) {
+	defaults := h.requestDefaults()
+	download := newFileDownload(requestOptions, newTransferSettings(requestOptions, defaults))
+
+	if download.segments > 1 && method == resty.MethodGet {
+		return h.doSegmentedFile(ctx, requestOptions, cookieJar, defaults, download)
+	}
+
+	return h.downloadFile(ctx, method, requestOptions, cookieJar, defaults, download)
+}
+
+// downloadFile downloads the file of requestOptions as a single stream.
+func (h *httpRunner) downloadFile(ctx context.Context, method string, requestOptions IFileRequestOptions, cookieJar []*http.Cookie, defaults requestDefaults, download fileDownload) (*resty.Response, error) {
+	var offset int64
+
+	return executeRequestWithHandler(ctx, method, requestOptions, defaults, func(ctx context.Context) (*resty.Request, error) {
+		request, err := h.newRequest(ctx, requestOptions, cookieJar)
+		if err != nil {
+			return nil, err
+		}
+
+		offset = download.prepare(request)
+
+		return request.SetDoNotParseResponse(true), nil
+	}, func(response *resty.Response) error {
+		return download.handle(method, response, offset)
+	})
+}
+
+func (h *httpRunner) doForm(ctx context.Context, method string, requestOptions IFormRequestOptions, cookieJar []*http.Cookie) (*resty.Response, error) {
+	defaults := h.requestDefaults()
+	transfer := newTransferSettings(requestOptions, defaults)
+
+	return executeRequest(ctx, method, requestOptions, defaults, func(ctx context.Context) (*resty.Request, error) {
+		request, err := h.newRequest(ctx, requestOptions, cookieJar)
+		if err != nil {
+			return nil, err
+		}
+
+		if requestOptions.IsFilesSet() && len(requestOptions.Files()) > 0 {
+			var values map[string]string
+			if requestOptions.IsValuesSet() {
+				values = requestOptions.Values()
+			}
+
+			streaming := requestOptions.IsStreamingOptionSet() && requestOptions.StreamingOption()
+			if err := setMultipartBody(ctx, request, values, requestOptions.Files(), streaming, transfer); err != nil {
+				return nil, err
+			}
+
+			return request, nil
+		}
+
+		if requestOptions.IsValuesSet() {
+			request.SetFormData(requestOptions.Values())
+		}
+
+		if len(request.Header.Get("Content-Type")) <= 0 {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		return request, nil
+	})
+}
+
+func (h *httpRunner) doBase(ctx context.Context, method string, requestOptions IBaseRequest, cookieJar []*http.Cookie) (*resty.Response, error) {
+	return executeRequest(ctx, method, requestOptions, h.requestDefaults(), func(ctx context.Context) (*resty.Request, error) {
+		return h.newRequest(ctx, requestOptions, cookieJar)
+	})
+}
+
+// newRequest creates a request carrying the default headers, the request headers and the matching cookies
+func (h *httpRunner) newRequest(ctx context.Context, requestOptions IBaseRequest, cookieJar []*http.Cookie) (*resty.Request, error) {
+	request := h.client.R().SetContext(ctx)
+
+	headers, profileTLS := h.defHeaders, h.profileTLS
+	if h.profiles != nil && h.profiles.rotation == RotatePerRequest {
+		profile := h.profiles.pick()
+		headers, profileTLS = h.profileHeaders(profile), profile.TLSHandshaker
+	}
+	if requestOptions.IsHeadersSet() {
+		headers = headers.merge(HeadersFromMap(requestOptions.Headers()))
+	}
+	if requestOptions.IsOrderedHeadersSet() {
+		headers = headers.merge(requestOptions.OrderedHeaders())
+	}
+
+	// keys are kept verbatim
+	for _, header := range headers {
+		request.Header[header.Key] = append(request.Header[header.Key], header.Value)
+	}
+
+	if h.orderHeaders || requestOptions.IsOrderedHeadersSet() {
+		request.SetContext(context.WithValue(request.Context(), headerOrderKey{}, headers.keys()))
+	}
+
+	// requests with a handshaker are sent by orderedTransport, which pools connections per handshaker
+	if h.tlsHandshaker == nil && profileTLS != nil {
+		request.SetContext(context.WithValue(request.Context(), tlsHandshakerKey{}, profileTLS))
+	}
+
+	forwarder, err := h.transports.forwarder(requestOptions)
+	if err != nil {
+		return nil, err
+	}
+	if forwarder != nil || requestOptions.IsStickyKeySet() {
+		selection := forwarderSelection{dialer: forwarder}
+		if requestOptions.IsStickyKeySet() {
+			selection.stickyKey = requestOptions.StickyKey()
+		}
+
+		request.SetContext(context.WithValue(request.Context(), forwarderKey{}, selection))
+	}
+
+	if len(cookieJar) > 0 {
+		if err := integrateCookies(resolveRequestUrl(h.baseUrl, requestOptions.Url()), request, cookieJar); err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
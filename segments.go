@@ -0,0 +1,222 @@
+package http_runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// minSegmentSize keeps small files from being split into many tiny requests.
+const minSegmentSize = 64 * 1024
+
+// doSegmentedFile downloads the file of requestOptions in byte ranges fetched at the same time.
+// Every segment is a request of its own with its own retries and its own connection, so with
+// a dialer spreading connections over several forwarders the segments use different forwarders.
+// A server that does not answer the probing range request with partial content gets its
+// response written as a single stream instead.
+func (h *httpRunner) doSegmentedFile(ctx context.Context, requestOptions IFileRequestOptions, cookieJar []*http.Cookie, defaults requestDefaults, download fileDownload) (*resty.Response, error) {
+	segments := download.segments
+
+	var (
+		size      int64
+		validator string
+		partial   bool
+	)
+
+	response, err := executeRequestWithHandler(ctx, resty.MethodGet, requestOptions, defaults, func(ctx context.Context) (*resty.Request, error) {
+		request, err := h.newRequest(ctx, requestOptions, cookieJar)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Range", "bytes=0-0")
+
+		return request.SetDoNotParseResponse(true), nil
+	}, func(response *resty.Response) error {
+		partial = response.StatusCode() == http.StatusPartialContent
+		if !partial {
+			// no range support, the response is the whole file
+			return download.handle(resty.MethodGet, response, 0)
+		}
+
+		_, _, size, _ = parseContentRange(response.Header().Get("Content-Range"))
+		validator = rangeValidator(response.Header())
+
+		rawBody := response.RawBody()
+		_, err := io.Copy(io.Discard, rawBody)
+		_ = rawBody.Close()
+
+		return err
+	})
+	if err != nil || !partial {
+		return response, err
+	}
+
+	// without a size or a validator the segments could come from different versions of the file
+	if size <= 0 || len(validator) <= 0 {
+		return h.downloadFile(ctx, resty.MethodGet, requestOptions, cookieJar, defaults, download)
+	}
+
+	if segmentCount := (size + minSegmentSize - 1) / minSegmentSize; int64(segments) > segmentCount {
+		segments = int(segmentCount)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(download.filePath), 0755); err != nil {
+		return response, err
+	}
+
+	file, err := os.OpenFile(download.partPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return response, err
+	}
+	_ = os.Remove(download.validatorPath())
+
+	if err := file.Truncate(size); err != nil {
+		_ = file.Close()
+		download.removePart()
+
+		return response, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := &segmentsProgress{progress: download.transfer.progress, total: size, startedAt: time.Now()}
+	// the segments share the limiter of the download
+	transfer := transferSettings{limiter: download.transfer.limiter}
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		firstErr error
+	)
+
+	segmentSize := size / int64(segments)
+	for segment := 0; segment < segments; segment++ {
+		first := int64(segment) * segmentSize
+		last := first + segmentSize - 1
+		if segment == segments-1 {
+			last = size - 1
+		}
+
+		wg.Add(1)
+		go func(first, last int64) {
+			defer wg.Done()
+
+			err := h.downloadSegment(ctx, requestOptions, cookieJar, defaults, file, validator, first, last, transfer, progress)
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				errMutex.Unlock()
+			}
+		}(first, last)
+	}
+
+	wg.Wait()
+
+	if closeErr := file.Close(); firstErr == nil {
+		firstErr = closeErr
+	}
+	if firstErr != nil {
+		download.removePart()
+
+		return response, firstErr
+	}
+
+	return response, download.complete(response)
+}
+
+// downloadSegment writes the bytes first to last of the file to their place in file. A retried
+// attempt continues after the bytes the failed attempt already wrote.
+func (h *httpRunner) downloadSegment(ctx context.Context, requestOptions IFileRequestOptions, cookieJar []*http.Cookie, defaults requestDefaults, file *os.File, validator string, first, last int64, transfer transferSettings, progress *segmentsProgress) error {
+	var written int64
+
+	_, err := executeRequestWithHandler(ctx, resty.MethodGet, requestOptions, defaults, func(ctx context.Context) (*resty.Request, error) {
+		request, err := h.newRequest(ctx, requestOptions, cookieJar)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first+written, last))
+		request.Header.Set("If-Range", validator)
+
+		return request.SetDoNotParseResponse(true), nil
+	}, func(response *resty.Response) error {
+		rawBody := response.RawBody()
+		defer rawBody.Close()
+
+		if response.StatusCode() != http.StatusPartialContent {
+			return fmt.Errorf("segment %d-%d of %v got status %v", first, last, requestOptions.FilePath(), response.Status())
+		}
+		if start, _, _, ok := parseContentRange(response.Header().Get("Content-Range")); !ok || start != first+written {
+			return fmt.Errorf("segment %d-%d of %v got Content-Range %q", first, last, requestOptions.FilePath(), response.Header().Get("Content-Range"))
+		}
+
+		reader := io.LimitReader(newTransferReader(response.Request.Context(), rawBody, transfer, 0, -1), last-first+1-written)
+
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := reader.Read(buffer)
+			if n > 0 {
+				if _, err := file.WriteAt(buffer[:n], first+written); err != nil {
+					return err
+				}
+
+				written += int64(n)
+				progress.add(int64(n))
+			}
+
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if written != last-first+1 {
+			return fmt.Errorf("segment %d-%d of %v stopped after %d bytes: %w", first, last, requestOptions.FilePath(), written, io.ErrUnexpectedEOF)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// segmentsProgress sums up the progress of all segments of a download.
+type segmentsProgress struct {
+	mutex     sync.Mutex
+	progress  ProgressFunc
+	done      int64
+	total     int64
+	startedAt time.Time
+}
+
+func (s *segmentsProgress) add(n int64) {
+	if s.progress == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.done += n
+
+	progress := Progress{Done: s.done, Total: s.total}
+	if elapsed := time.Since(s.startedAt).Seconds(); elapsed > 0 {
+		progress.Speed = float64(s.done) / elapsed
+	}
+
+	s.progress(progress)
+}
@@ -0,0 +1,149 @@
+package http_runner
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestGetFileSegments(t *testing.T) {
+	content := make([]byte, 512*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	var (
+		mutex     sync.Mutex
+		ranges    []string
+		inFlight  int
+		maxFlight int
+		noRanges  bool
+		abortOnce bool
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		inFlight++
+		if inFlight > maxFlight {
+			maxFlight = inFlight
+		}
+		abort := abortOnce && r.Header.Get("Range") == "bytes=131072-262143"
+		if abort {
+			abortOnce = false
+		}
+		mutex.Unlock()
+
+		defer func() {
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+		}()
+
+		if noRanges {
+			_, _ = w.Write(content)
+			return
+		}
+
+		// the segments have to overlap for the test to see them run at the same time
+		if r.Header.Get("Range") != "bytes=0-0" {
+			time.Sleep(time.Millisecond * 50)
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+
+		if abort {
+			w.Header().Set("Content-Range", "bytes 131072-262143/"+strconv.Itoa(len(content)))
+			w.Header().Set("Content-Length", "131072")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(content[131072 : 131072+1000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer, WithRetryPolicy(RetryPolicy{MinBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		noRanges   bool
+		abort      bool
+		wantRanges []string
+		wantFlight int
+	}{
+		{name: "Segments", wantRanges: []string{"bytes=0-0", "bytes=0-131071", "bytes=131072-262143", "bytes=262144-393215", "bytes=393216-524287"}, wantFlight: 4},
+		{name: "RetrySegment", abort: true, wantRanges: []string{"bytes=0-0", "bytes=0-131071", "bytes=131072-262143", "bytes=132072-262143", "bytes=262144-393215", "bytes=393216-524287"}, wantFlight: 4},
+		{name: "NoRanges", noRanges: true, wantRanges: []string{"bytes=0-0"}, wantFlight: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestGetFileSegments-"+tt.name, func(t *testing.T) {
+			mutex.Lock()
+			ranges, maxFlight, noRanges, abortOnce = nil, 0, tt.noRanges, tt.abort
+			mutex.Unlock()
+
+			var last Progress
+
+			filePath := filepath.Join(t.TempDir(), "file.bin")
+
+			fileRequest := NewFileRequestOptions(server.URL, filePath)
+			fileRequest.SetSegmentsOption(4)
+			fileRequest.SetProgressOption(func(progress Progress) {
+				last = progress
+			})
+
+			if _, err := directHttpRunner.GetFile(fileRequest); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("downloaded file differs from the served one")
+			}
+			if last.Done != int64(len(content)) {
+				t.Errorf("last progress = %+v, want %v bytes done", last, len(content))
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			gotRanges := map[string]bool{}
+			for _, value := range ranges {
+				gotRanges[value] = true
+			}
+			if len(ranges) != len(tt.wantRanges) {
+				t.Errorf("ranges = %v, want %v", ranges, tt.wantRanges)
+			}
+			for _, value := range tt.wantRanges {
+				if !gotRanges[value] {
+					t.Errorf("ranges = %v, want %v", ranges, tt.wantRanges)
+				}
+			}
+			if maxFlight != tt.wantFlight {
+				t.Errorf("requests at the same time = %v, want %v", maxFlight, tt.wantFlight)
+			}
+		})
+	}
+}
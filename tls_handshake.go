@@ -0,0 +1,138 @@
+package http_runner
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// TLSHandshaker runs the client side of the TLS handshake over conn, which is already dialed
+// through the dialer of the runner, so a proxy dialer keeps working. config carries the server
+// name and the verification settings of the runner, a handshaker must honor them. Connections
+// are pooled per handshaker, it has to be comparable. A *tls.Conn or uTLS connection on which
+// the server picked h2 speaks HTTP/2, any other HTTP/1.1.
+type TLSHandshaker interface {
+	Handshake(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error)
+}
+
+type tlsHandshakerKey struct{}
+
+var (
+	// StdTLSHandshaker sends the ClientHello of crypto/tls.
+	StdTLSHandshaker TLSHandshaker = stdTLSHandshaker{}
+
+	// ChromeTLSHandshaker, FirefoxTLSHandshaker, SafariTLSHandshaker and IOSTLSHandshaker send
+	// the ClientHello of the latest version of the browser uTLS knows.
+	ChromeTLSHandshaker  = NewUTLSHandshaker(utls.HelloChrome_Auto)
+	FirefoxTLSHandshaker = NewUTLSHandshaker(utls.HelloFirefox_Auto)
+	SafariTLSHandshaker  = NewUTLSHandshaker(utls.HelloSafari_Auto)
+	IOSTLSHandshaker     = NewUTLSHandshaker(utls.HelloIOS_Auto)
+)
+
+type stdTLSHandshaker struct{}
+
+func (stdTLSHandshaker) Handshake(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// NewUTLSHandshaker returns a handshaker sending the ClientHello of id, its ALPN extension
+// included: the browser presets offer h2, which the runner speaks when the server picks it,
+// whatever WithHTTP2 says. The headers of HTTP/2 requests are sent in the order of
+// golang.org/x/net/http2, not in that of ordered headers.
+func NewUTLSHandshaker(id utls.ClientHelloID) TLSHandshaker {
+	return utlsHandshaker{id: id}
+}
+
+type utlsHandshaker struct {
+	id utls.ClientHelloID
+}
+
+func (u utlsHandshaker) Handshake(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	// a spec holds the state of its extensions, every handshake needs a fresh one
+	spec, err := utls.UTLSIdToSpec(u.id)
+	if err != nil {
+		return nil, err
+	}
+
+	uConn := utls.UClient(conn, utlsConfig(config), utls.HelloCustom)
+	if err := uConn.ApplyPreset(&spec); err != nil {
+		return nil, err
+	}
+	if err := uConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return uConn, nil
+}
+
+// negotiatedProtocol returns the protocol the server picked through ALPN, empty when it is not known.
+func negotiatedProtocol(conn net.Conn) string {
+	switch conn := conn.(type) {
+	case *tls.Conn:
+		return conn.ConnectionState().NegotiatedProtocol
+	case *utls.UConn:
+		return conn.ConnectionState().NegotiatedProtocol
+	}
+
+	return ""
+}
+
+// utlsConfig copies the settings of config uTLS has a counterpart for.
+func utlsConfig(config *tls.Config) *utls.Config {
+	uConfig := &utls.Config{
+		ServerName:            config.ServerName,
+		RootCAs:               config.RootCAs,
+		InsecureSkipVerify:    config.InsecureSkipVerify,
+		VerifyPeerCertificate: config.VerifyPeerCertificate,
+		MinVersion:            config.MinVersion,
+		MaxVersion:            config.MaxVersion,
+		KeyLogWriter:          config.KeyLogWriter,
+	}
+
+	for _, certificate := range config.Certificates {
+		uConfig.Certificates = append(uConfig.Certificates, utls.Certificate{
+			Certificate: certificate.Certificate,
+			PrivateKey:  certificate.PrivateKey,
+			OCSPStaple:  certificate.OCSPStaple,
+			Leaf:        certificate.Leaf,
+		})
+	}
+
+	return uConfig
+}
+
+// handshakeTLS runs the handshake of handshaker, crypto/tls when nil, over conn with a copy of
+// baseConfig for serverName. conn is closed when the handshake fails.
+func handshakeTLS(ctx context.Context, conn net.Conn, handshaker TLSHandshaker, baseConfig *tls.Config, serverName string, nextProtos []string) (net.Conn, error) {
+	if handshaker == nil {
+		handshaker = StdTLSHandshaker
+	}
+
+	var config *tls.Config
+	if baseConfig != nil {
+		config = baseConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	if len(config.ServerName) <= 0 {
+		config.ServerName = serverName
+	}
+	if nextProtos != nil {
+		config.NextProtos = nextProtos
+	}
+
+	tlsConn, err := handshaker.Handshake(ctx, conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
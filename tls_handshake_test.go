@@ -0,0 +1,219 @@
+package http_runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestTLSHandshaker(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		hello *tls.ClientHelloInfo
+	)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			mutex.Lock()
+			hello = info
+			mutex.Unlock()
+
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// handshake sends a request and returns the ClientHello the server got
+	handshake := func(t *testing.T, options ...Option) tls.ClientHelloInfo {
+		runner, err := NewHttpRunner(directDialer, append([]Option{WithTLSConfig(&tls.Config{RootCAs: rootCAs})}, options...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(server.URL)); err != nil {
+			t.Fatal(err)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if hello == nil {
+			t.Fatal("no ClientHello recorded")
+		}
+
+		return *hello
+	}
+
+	// greased reports whether the ClientHello offers GREASE cipher suites, as Chrome does and crypto/tls does not
+	greased := func(hello tls.ClientHelloInfo) bool {
+		for _, cipherSuite := range hello.CipherSuites {
+			if isGrease(cipherSuite) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// matchesPreset checks the ALPN and the extensions of hello against the ClientHello of id
+	matchesPreset := func(t *testing.T, hello tls.ClientHelloInfo, id utls.ClientHelloID) {
+		alpn, extensions := presetHello(t, id)
+
+		if !reflect.DeepEqual(hello.SupportedProtos, alpn) {
+			t.Errorf("ALPN = %v, want %v", hello.SupportedProtos, alpn)
+		}
+		if got := helloExtensions(hello.Extensions); !reflect.DeepEqual(got, extensions) {
+			t.Errorf("extensions = %v, want %v", got, extensions)
+		}
+	}
+
+	t.Run("TestTLSHandshaker-Std", func(t *testing.T) {
+		hello := handshake(t, WithTLSHandshaker(StdTLSHandshaker))
+		if greased(hello) {
+			t.Error("crypto/tls ClientHello has GREASE")
+		}
+		if !reflect.DeepEqual(hello.SupportedProtos, []string{"http/1.1"}) {
+			t.Errorf("ALPN = %v, want %v", hello.SupportedProtos, []string{"http/1.1"})
+		}
+	})
+	t.Run("TestTLSHandshaker-Chrome", func(t *testing.T) {
+		hello := handshake(t, WithTLSHandshaker(ChromeTLSHandshaker))
+		if !greased(hello) {
+			t.Error("Chrome ClientHello has no GREASE")
+		}
+		matchesPreset(t, hello, utls.HelloChrome_Auto)
+	})
+	t.Run("TestTLSHandshaker-Profile", func(t *testing.T) {
+		matchesPreset(t, handshake(t, WithBrowserProfile(ChromeDesktop)), utls.HelloChrome_Auto)
+		if greased(handshake(t, WithBrowserProfile(ChromeDesktop), WithTLSHandshaker(StdTLSHandshaker))) {
+			t.Error("WithTLSHandshaker does not replace the handshaker of the profile")
+		}
+	})
+	t.Run("TestTLSHandshaker-Firefox", func(t *testing.T) {
+		hello := handshake(t, WithBrowserProfile(FirefoxDesktop))
+		if greased(hello) {
+			t.Error("ClientHello of the Firefox profile has GREASE")
+		}
+		matchesPreset(t, hello, utls.HelloFirefox_Auto)
+	})
+	t.Run("TestTLSHandshaker-HTTP2", func(t *testing.T) {
+		h2Server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}))
+		h2Server.EnableHTTP2 = true
+		h2Server.StartTLS()
+		defer h2Server.Close()
+
+		h2RootCAs := x509.NewCertPool()
+		h2RootCAs.AddCert(h2Server.Certificate())
+
+		tests := []struct {
+			name    string
+			options []Option
+			want    string
+		}{
+			{"Profile", []Option{WithBrowserProfile(ChromeDesktop)}, "HTTP/2.0"},
+			{"Handshaker", []Option{WithTLSHandshaker(FirefoxTLSHandshaker)}, "HTTP/2.0"},
+			{"Std", []Option{WithTLSHandshaker(StdTLSHandshaker)}, "HTTP/1.1"},
+		}
+
+		for _, tt := range tests {
+			runner, err := NewHttpRunner(directDialer, append([]Option{WithTLSConfig(&tls.Config{RootCAs: h2RootCAs})}, tt.options...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the second request reuses the connection of the first
+			for i := 0; i < 2; i++ {
+				response, err := runner.GetHtml(NewHtmlRequestOptions(h2Server.URL))
+				if err != nil {
+					t.Fatalf("%v: %v", tt.name, err)
+				}
+				if response.String() != tt.want {
+					t.Errorf("%v: protocol = %q, want %q", tt.name, response.String(), tt.want)
+				}
+			}
+		}
+	})
+}
+
+func isGrease(value uint16) bool {
+	return value&0x0f0f == 0x0a0a
+}
+
+// helloExtensions returns the sorted ids of extensions, GREASE and padding left out: their values
+// and the need for padding change with every ClientHello.
+func helloExtensions(extensions []uint16) []uint16 {
+	result := make([]uint16, 0, len(extensions))
+	for _, extension := range extensions {
+		if !isGrease(extension) && extension != 21 {
+			result = append(result, extension)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result
+}
+
+// presetHello returns the ALPN protocols and the extensions of the ClientHello of id for an ip
+// address, which gets no server name extension.
+func presetHello(t *testing.T, id utls.ClientHelloID) ([]string, []uint16) {
+	spec, err := utls.UTLSIdToSpec(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	uConn := utls.UClient(clientConn, &utls.Config{ServerName: "127.0.0.1"}, utls.HelloCustom)
+	if err := uConn.ApplyPreset(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := uConn.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		alpn []string
+		ids  []uint16
+	)
+	for _, extension := range uConn.Extensions {
+		if alpnExtension, ok := extension.(*utls.ALPNExtension); ok {
+			alpn = alpnExtension.AlpnProtocols
+		}
+
+		data := make([]byte, extension.Len())
+		if _, err := io.ReadFull(extension, data); err != nil && !errors.Is(err, io.EOF) {
+			t.Fatal(err)
+		}
+		if len(data) >= 2 {
+			ids = append(ids, binary.BigEndian.Uint16(data))
+		}
+	}
+
+	return alpn, helloExtensions(ids)
+}
@@ -0,0 +1,151 @@
+package http_runner
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrPublicKeyPin is returned when no certificate of the server matches a pinned public key.
+var ErrPublicKeyPin = errors.New("server public key is not pinned")
+
+// TLSOptions configures how a runner verifies servers and authenticates itself, see WithTLSOptions.
+type TLSOptions struct {
+	// CAFiles and CAPEM are PEM encoded certificates trusted next to the system roots,
+	// or instead of them with ExcludeSystemCAs.
+	CAFiles          []string
+	CAPEM            []byte
+	ExcludeSystemCAs bool
+
+	// ClientCertificates are presented to servers asking for one, next to the PEM encoded
+	// pair of ClientCertFile and ClientKeyFile.
+	ClientCertificates []tls.Certificate
+	ClientCertFile     string
+	ClientKeyFile      string
+
+	// PinnedPublicKeys are pins made by PublicKeyPin, "sha256/" followed by the base64 SHA-256
+	// of a subject public key info. A server is accepted only when its verified chain holds a
+	// pinned key, or with InsecureSkipVerify when its own certificate has one.
+	PinnedPublicKeys []string
+
+	// InsecureSkipVerify accepts any certificate, meant for local test servers.
+	InsecureSkipVerify bool
+
+	ServerName string
+	MinVersion uint16
+}
+
+// PublicKeyPin returns the pin of the public key of certificate, the format of curl's --pinnedpubkey.
+func PublicKeyPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// apply returns a copy of base, nil meaning an empty configuration, with the options set.
+func (o TLSOptions) apply(base *tls.Config) (*tls.Config, error) {
+	config := &tls.Config{}
+	if base != nil {
+		config = base.Clone()
+	}
+
+	if len(o.CAFiles) > 0 || len(o.CAPEM) > 0 {
+		rootCAs := x509.NewCertPool()
+		if config.RootCAs != nil {
+			rootCAs = config.RootCAs.Clone()
+		} else if !o.ExcludeSystemCAs {
+			systemCAs, err := x509.SystemCertPool()
+			if err != nil {
+				return nil, fmt.Errorf("loading system CAs: %w", err)
+			}
+			rootCAs = systemCAs
+		}
+
+		for _, caFile := range o.CAFiles {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			if !rootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in %v", caFile)
+			}
+		}
+		if len(o.CAPEM) > 0 && !rootCAs.AppendCertsFromPEM(o.CAPEM) {
+			return nil, errors.New("no certificate found in CAPEM")
+		}
+
+		config.RootCAs = rootCAs
+	}
+
+	config.Certificates = append(config.Certificates, o.ClientCertificates...)
+	if len(o.ClientCertFile) > 0 || len(o.ClientKeyFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = append(config.Certificates, certificate)
+	}
+
+	if len(o.PinnedPublicKeys) > 0 {
+		pins := make(map[string]bool, len(o.PinnedPublicKeys))
+		for _, pin := range o.PinnedPublicKeys {
+			if !strings.HasPrefix(pin, "sha256/") {
+				return nil, fmt.Errorf("pin %q does not start with sha256/", pin)
+			}
+			pins[pin] = true
+		}
+
+		config.VerifyPeerCertificate = verifyPublicKeyPins(pins, config.VerifyPeerCertificate)
+	}
+
+	if o.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if len(o.ServerName) > 0 {
+		config.ServerName = o.ServerName
+	}
+	if o.MinVersion > 0 {
+		config.MinVersion = o.MinVersion
+	}
+
+	return config, nil
+}
+
+// verifyPublicKeyPins accepts a verified chain holding a pinned key. Without verification the
+// chain is whatever the server sent, so only the key of the server's own certificate counts.
+func verifyPublicKeyPins(pins map[string]bool, next func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		pinned := false
+
+		if len(verifiedChains) > 0 {
+			for _, chain := range verifiedChains {
+				for _, certificate := range chain {
+					if pins[PublicKeyPin(certificate)] {
+						pinned = true
+					}
+				}
+			}
+		} else if len(rawCerts) > 0 {
+			certificate, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			pinned = pins[PublicKeyPin(certificate)]
+		}
+
+		if !pinned {
+			return ErrPublicKeyPin
+		}
+
+		if next != nil {
+			return next(rawCerts, verifiedChains)
+		}
+
+		return nil
+	}
+}
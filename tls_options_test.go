@@ -0,0 +1,131 @@
+package http_runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestTLSOptions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("x-client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	serverPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, serverPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(t *testing.T, options TLSOptions) (string, error) {
+		runner, err := NewHttpRunner(directDialer, WithTLSOptions(options), WithRetryCount(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL))
+		if err != nil {
+			return "", err
+		}
+
+		return response.Header().Get("x-client"), nil
+	}
+
+	t.Run("TestTLSOptions-CA", func(t *testing.T) {
+		if _, err := get(t, TLSOptions{}); err == nil {
+			t.Error("error = nil without the CA, want certificate error")
+		}
+		if _, err := get(t, TLSOptions{CAFiles: []string{caFile}}); err != nil {
+			t.Error(err)
+		}
+		if _, err := get(t, TLSOptions{CAPEM: serverPEM, ExcludeSystemCAs: true}); err != nil {
+			t.Error(err)
+		}
+
+		if _, err := NewHttpRunner(directDialer, WithTLSOptions(TLSOptions{CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}})); err == nil {
+			t.Error("NewHttpRunner() error = nil with a missing CA file")
+		}
+	})
+	t.Run("TestTLSOptions-Insecure", func(t *testing.T) {
+		if _, err := get(t, TLSOptions{InsecureSkipVerify: true}); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("TestTLSOptions-Pinning", func(t *testing.T) {
+		pin := PublicKeyPin(server.Certificate())
+
+		if _, err := get(t, TLSOptions{CAPEM: serverPEM, PinnedPublicKeys: []string{pin}}); err != nil {
+			t.Error(err)
+		}
+		if _, err := get(t, TLSOptions{InsecureSkipVerify: true, PinnedPublicKeys: []string{pin}}); err != nil {
+			t.Error(err)
+		}
+
+		otherPin := "sha256/" + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+		if _, err := get(t, TLSOptions{InsecureSkipVerify: true, PinnedPublicKeys: []string{otherPin}}); !errors.Is(err, ErrPublicKeyPin) {
+			t.Errorf("error = %v, want %v", err, ErrPublicKeyPin)
+		}
+	})
+	t.Run("TestTLSOptions-ClientCertificate", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "test-client"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyBytes, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		certFile := filepath.Join(t.TempDir(), "client.pem")
+		keyFile := filepath.Join(t.TempDir(), "client.key")
+		if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		client, err := get(t, TLSOptions{CAPEM: serverPEM, ClientCertFile: certFile, ClientKeyFile: keyFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client != "test-client" {
+			t.Errorf("client certificate = %q, want %q", client, "test-client")
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package http_runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Progress describes a running GetFile download or PostForm upload.
+type Progress struct {
+	Done  int64   // bytes transferred, including a part downloaded by an earlier attempt
+	Total int64   // -1 when the size is unknown
+	Speed float64 // bytes per second since the attempt started
+}
+
+// ProgressFunc is called whenever a download or upload makes progress. It is called from the
+// goroutine doing the transfer, so it should return quickly.
+type ProgressFunc func(progress Progress)
+
+// ITransferRequestOptions are the options of requests transferring files, they override
+// the WithProgress and WithRateLimit options of the runner.
+type ITransferRequestOptions interface {
+	IsProgressOptionSet() bool
+	SetProgressOption(progress ProgressFunc)
+	ProgressOption() ProgressFunc
+
+	IsRateLimitOptionSet() bool
+	SetRateLimitOption(bytesPerSecond int64)
+	RateLimitOption() int64
+}
+
+type transferRequestOptions struct {
+	progress  *ProgressFunc
+	rateLimit *int64
+}
+
+func (t *transferRequestOptions) IsProgressOptionSet() bool {
+	return t.progress != nil
+}
+func (t *transferRequestOptions) SetProgressOption(progress ProgressFunc) {
+	t.progress = &progress
+}
+func (t *transferRequestOptions) ProgressOption() ProgressFunc {
+	return *t.progress
+}
+
+func (t *transferRequestOptions) IsRateLimitOptionSet() bool {
+	return t.rateLimit != nil
+}
+
+// SetRateLimitOption caps the transfer at bytesPerSecond, zero means no limit.
+func (t *transferRequestOptions) SetRateLimitOption(bytesPerSecond int64) {
+	t.rateLimit = &bytesPerSecond
+}
+func (t *transferRequestOptions) RateLimitOption() int64 {
+	return *t.rateLimit
+}
+
+//
+
+type transferSettings struct {
+	progress ProgressFunc
+	// limiter is nil without a rate limit
+	limiter *rateLimiter
+}
+
+func newTransferSettings(requestOptions ITransferRequestOptions, defaults requestDefaults) transferSettings {
+	settings := transferSettings{
+		progress: defaults.progress,
+		limiter:  defaults.rateLimiter,
+	}
+
+	if requestOptions.IsProgressOptionSet() {
+		settings.progress = requestOptions.ProgressOption()
+	}
+	if requestOptions.IsRateLimitOptionSet() {
+		settings.limiter = newRateLimiter(requestOptions.RateLimitOption())
+	}
+
+	return settings
+}
+
+func (t transferSettings) isSet() bool {
+	return t.progress != nil || t.limiter != nil
+}
+
+// rateLimiter spreads the bytes of the transfers sharing it at bytesPerSecond: the transfers of
+// a runner, or those of a request with a rate limit option of its own.
+type rateLimiter struct {
+	bytesPerSecond int64
+
+	mutex sync.Mutex
+	// next is when the bytes taken so far are transferred at the rate
+	next time.Time
+}
+
+// newRateLimiter returns nil for no limit.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// wait takes n transferred bytes, waiting until they fit in the rate or ctx is done.
+func (r *rateLimiter) wait(ctx context.Context, n int) error {
+	r.mutex.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	r.next = r.next.Add(time.Duration(n) * time.Second / time.Duration(r.bytesPerSecond))
+	until := r.next
+	r.mutex.Unlock()
+
+	waitTime := time.Until(until)
+	if waitTime <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(waitTime)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transferReader reports the progress of reader and slows it down to the rate limit.
+type transferReader struct {
+	ctx       context.Context
+	reader    io.Reader
+	settings  transferSettings
+	offset    int64
+	done      int64
+	total     int64
+	startedAt time.Time
+}
+
+// newTransferReader wraps reader, which starts offset bytes into a transfer of total bytes.
+// reader is returned as it is when there is nothing to report or limit.
+func newTransferReader(ctx context.Context, reader io.Reader, settings transferSettings, offset, total int64) io.Reader {
+	if !settings.isSet() {
+		return reader
+	}
+
+	return &transferReader{
+		ctx:       ctx,
+		reader:    reader,
+		settings:  settings,
+		offset:    offset,
+		done:      offset,
+		total:     total,
+		startedAt: time.Now(),
+	}
+}
+
+func (t *transferReader) Read(p []byte) (int, error) {
+	// reading at most a tenth of a second worth of bytes keeps the rate smooth
+	if t.settings.limiter != nil {
+		if chunk := t.settings.limiter.bytesPerSecond / 10; chunk > 0 && int64(len(p)) > chunk {
+			p = p[:chunk]
+		}
+	}
+
+	n, err := t.reader.Read(p)
+	if n <= 0 {
+		return n, err
+	}
+
+	t.done += int64(n)
+
+	if t.settings.limiter != nil {
+		if err := t.settings.limiter.wait(t.ctx, n); err != nil {
+			return n, err
+		}
+	}
+
+	if t.settings.progress != nil {
+		progress := Progress{Done: t.done, Total: t.total}
+		if elapsed := time.Since(t.startedAt).Seconds(); elapsed > 0 {
+			progress.Speed = float64(t.done-t.offset) / elapsed
+		}
+
+		t.settings.progress(progress)
+	}
+
+	return n, err
+}
+
+func (t *transferReader) Close() error {
+	if closer, ok := t.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// ErrRedirectedUpload is returned for streamed uploads, and uploads with progress or a rate
+// limit, redirected with 307 or 308: their body would have to be sent again.
+var ErrRedirectedUpload = errors.New("upload redirected with its body, which can not be sent again")
+
+type requestBodyKey struct{}
+
+// requestBody is an upload body handed to the transport in the request context, resty would
+// otherwise read an io.Reader body into memory before sending it.
+type requestBody struct {
+	reader io.ReadCloser
+	size   int64 // -1 when unknown
+	taken  int32
+}
+
+func setRequestBody(request *resty.Request, reader io.Reader, size int64) {
+	readCloser, ok := reader.(io.ReadCloser)
+	if !ok {
+		readCloser = io.NopCloser(reader)
+	}
+
+	request.SetContext(context.WithValue(request.Context(), requestBodyKey{}, &requestBody{reader: readCloser, size: size}))
+}
+
+// requestBodyTransport sends the body set by setRequestBody.
+type requestBodyTransport struct {
+	base http.RoundTripper
+}
+
+func (r *requestBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := req.Context().Value(requestBodyKey{}).(*requestBody)
+	if !ok {
+		return r.base.RoundTrip(req)
+	}
+
+	// redirected requests share the context, only the first one sends the body
+	if !atomic.CompareAndSwapInt32(&body.taken, 0, 1) {
+		// 307 and 308 keep the method and the body, which was read by the first request
+		if req.Response != nil && (req.Response.StatusCode == http.StatusTemporaryRedirect || req.Response.StatusCode == http.StatusPermanentRedirect) {
+			closeRequestBody(req)
+			return nil, fmt.Errorf("%w: %v", ErrRedirectedUpload, req.Response.Status)
+		}
+
+		return r.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = body.reader
+	req.ContentLength = body.size
+	req.GetBody = nil
+
+	return r.base.RoundTrip(req)
+}
@@ -0,0 +1,207 @@
+package http_runner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+func TestTransferProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20*1024)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			received, _ := io.Copy(io.Discard, r.Body)
+			w.Header().Set("x-received", strconv.FormatInt(received, 10))
+			w.Header().Set("x-content-length", strconv.FormatInt(r.ContentLength, 10))
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mutex       sync.Mutex
+		runnerCalls int
+	)
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer, WithProgress(func(progress Progress) {
+		mutex.Lock()
+		runnerCalls++
+		mutex.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestTransferProgress-Download", func(t *testing.T) {
+		var last Progress
+
+		fileRequest := NewFileRequestOptions(server.URL, filepath.Join(t.TempDir(), "file.bin"))
+		fileRequest.SetProgressOption(func(progress Progress) {
+			if progress.Done < last.Done {
+				t.Errorf("progress went back from %v to %v", last.Done, progress.Done)
+			}
+			last = progress
+		})
+		fileRequest.SetRateLimitOption(int64(len(content)) * 4)
+
+		startedAt := time.Now()
+
+		if _, err := directHttpRunner.GetFile(fileRequest); err != nil {
+			t.Fatal(err)
+		}
+
+		if elapsed := time.Since(startedAt); elapsed < time.Millisecond*200 {
+			t.Errorf("download took %v, want at least 250ms at the rate limit", elapsed)
+		}
+		if last.Done != int64(len(content)) || last.Total != int64(len(content)) {
+			t.Errorf("last progress = %+v, want %v of %v bytes", last, len(content), len(content))
+		}
+		if last.Speed <= 0 {
+			t.Errorf("last progress speed = %v, want more than 0", last.Speed)
+		}
+		if runnerCalls != 0 {
+			t.Errorf("runner progress called %v times, want the request progress only", runnerCalls)
+		}
+	})
+	t.Run("TestTransferProgress-SharedRateLimit", func(t *testing.T) {
+		// the runner limit caps concurrent downloads together
+		limitedRunner, err := NewDirectHttpRunner(directDialer, WithRateLimit(int64(len(content))*4))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		startedAt := time.Now()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				fileRequest := NewFileRequestOptions(server.URL, filepath.Join(t.TempDir(), "file"+strconv.Itoa(i)+".bin"))
+				if _, err := limitedRunner.GetFile(fileRequest); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if elapsed := time.Since(startedAt); elapsed < time.Millisecond*450 {
+			t.Errorf("downloads took %v, want at least 500ms at the shared rate limit", elapsed)
+		}
+	})
+	t.Run("TestTransferProgress-Upload", func(t *testing.T) {
+		for _, streaming := range []bool{false, true} {
+			runnerCalls = 0
+
+			formRequest := NewFormRequestOptions(server.URL)
+			formRequest.SetFiles(map[string]FileInfo{"upload": NewFileInfoFromBytes("file.bin", content)})
+			formRequest.SetStreamingOption(streaming)
+
+			response, err := directHttpRunner.PostForm(formRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			received, _ := strconv.ParseInt(response.Header().Get("x-received"), 10, 64)
+			if received <= int64(len(content)) {
+				t.Errorf("received %v bytes, want the %v bytes of the file and more", received, len(content))
+			}
+			if runnerCalls == 0 {
+				t.Errorf("runner progress was not called, streaming %v", streaming)
+			}
+
+			wantContentLength := strconv.FormatInt(received, 10)
+			if streaming {
+				wantContentLength = "-1"
+			}
+			if got := response.Header().Get("x-content-length"); got != wantContentLength {
+				t.Errorf("Content-Length = %v, want %v, streaming %v", got, wantContentLength, streaming)
+			}
+		}
+	})
+}
+
+func TestPostFormRedirect(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1024)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+
+		statusCode, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		http.Redirect(w, r, "/final", statusCode)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.Copy(io.Discard, r.Body)
+		w.Header().Set("x-method", r.Method)
+		w.Header().Set("x-received", strconv.FormatInt(received, 10))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directHttpRunner, err := NewDirectHttpRunner(directDialer, WithRetryCount(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, streaming := range []bool{false, true} {
+		newRequest := func(statusCode int) IFormRequestOptions {
+			formRequest := NewFormRequestOptions(server.URL + "/upload?status=" + strconv.Itoa(statusCode))
+			formRequest.SetFiles(map[string]FileInfo{"upload": NewFileInfoFromBytes("file.bin", content)})
+			formRequest.SetStreamingOption(streaming)
+
+			return formRequest
+		}
+
+		// a buffered upload is sent again to the target of the redirect, a streamed one can not be
+		for _, statusCode := range []int{http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+			response, err := directHttpRunner.PostForm(newRequest(statusCode))
+			if streaming {
+				if !errors.Is(err, ErrRedirectedUpload) {
+					t.Errorf("error = %v, want %v, status %v", err, ErrRedirectedUpload, statusCode)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if received, _ := strconv.ParseInt(response.Header().Get("x-received"), 10, 64); response.Header().Get("x-method") != http.MethodPost || received <= int64(len(content)) {
+				t.Errorf("%v redirect got %v with %v bytes, want the whole upload", statusCode, response.Header().Get("x-method"), received)
+			}
+		}
+
+		// a 303 turns the upload into a GET without a body
+		response, err := directHttpRunner.PostForm(newRequest(http.StatusSeeOther))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Header().Get("x-method") != http.MethodGet || response.Header().Get("x-received") != "0" {
+			t.Errorf("303 redirect got %v with %v bytes, want a GET without a body, streaming %v", response.Header().Get("x-method"), response.Header().Get("x-received"), streaming)
+		}
+	}
+}
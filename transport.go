@@ -0,0 +1,180 @@
+package http_runner
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// TransportOptions tunes the connection pool of a runner, see WithTransportOptions. Zero
+// fields keep the values of DefaultTransportOptions.
+type TransportOptions struct {
+	// MaxIdleConns caps the idle connections kept over all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps the idle connections kept per host, the rest are closed once
+	// their response is read. High fan-out crawls want it close to their concurrency.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps all connections per host, requests over it wait for a free one.
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	ExpectContinueTimeout time.Duration
+	DisableKeepAlives     bool
+}
+
+// DefaultTransportOptions are the values of http.DefaultTransport, with more idle connections per host.
+var DefaultTransportOptions = TransportOptions{
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+	IdleConnTimeout:       time.Second * 90,
+	TLSHandshakeTimeout:   time.Second * 10,
+	ExpectContinueTimeout: time.Second,
+}
+
+// HTTP2Mode decides whether a runner speaks HTTP/2.
+type HTTP2Mode int
+
+const (
+	// HTTP2Off speaks HTTP/1.1 only.
+	HTTP2Off HTTP2Mode = iota
+	// HTTP2Auto speaks HTTP/2 with https servers offering it through ALPN.
+	HTTP2Auto
+	// HTTP2PriorKnowledge speaks HTTP/2 with every server, over cleartext (h2c) for http urls.
+	HTTP2PriorKnowledge
+)
+
+// merge fills the zero fields of o from defaults.
+func (o TransportOptions) merge(defaults TransportOptions) TransportOptions {
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost <= 0 {
+		o.MaxIdleConnsPerHost = defaults.MaxIdleConnsPerHost
+	}
+	if o.MaxConnsPerHost <= 0 {
+		o.MaxConnsPerHost = defaults.MaxConnsPerHost
+	}
+	if o.IdleConnTimeout <= 0 {
+		o.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	if o.TLSHandshakeTimeout <= 0 {
+		o.TLSHandshakeTimeout = defaults.TLSHandshakeTimeout
+	}
+	if o.ResponseHeaderTimeout <= 0 {
+		o.ResponseHeaderTimeout = defaults.ResponseHeaderTimeout
+	}
+	if o.ExpectContinueTimeout <= 0 {
+		o.ExpectContinueTimeout = defaults.ExpectContinueTimeout
+	}
+
+	return o
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// transportStack is the transport of one dialer: the pooled transport, the h2c transport of
+// HTTP2PriorKnowledge and the orderedTransport in front of them.
+type transportStack struct {
+	transport    *http.Transport
+	h2c          *http2.Transport
+	roundTripper *orderedTransport
+}
+
+func newTransportStack(config runnerConfig, dial dialFunc) (*transportStack, error) {
+	options := config.transportOptions.merge(DefaultTransportOptions)
+
+	transport, h2c, err := newTransport(options, config.http2Mode, dial, config.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	var base http.RoundTripper = transport
+	if h2c != nil {
+		base = &h2cTransport{base: transport, h2c: h2c}
+	}
+
+	return &transportStack{
+		transport: transport,
+		h2c:       h2c,
+		roundTripper: &orderedTransport{
+			base:          base,
+			dial:          dial,
+			tlsConfig:     config.tlsConfig,
+			tlsHandshaker: config.tlsHandshaker,
+			options:       options,
+			h2:            &http2.Transport{IdleConnTimeout: options.IdleConnTimeout},
+		},
+	}, nil
+}
+
+func (t *transportStack) closeIdleConnections() {
+	t.transport.CloseIdleConnections()
+	if t.h2c != nil {
+		t.h2c.CloseIdleConnections()
+	}
+	t.roundTripper.pool.closeIdleConnections()
+}
+
+// newTransport builds the pooled transport of a runner. The https requests of a TLSHandshaker
+// go to orderedTransport instead, net/http can only speak HTTP/1.1 over connections not being *tls.Conn.
+func newTransport(options TransportOptions, mode HTTP2Mode, dial dialFunc, tlsConfig *tls.Config) (*http.Transport, *http2.Transport, error) {
+	// ConfigureTransports changes the ALPN protocols of the configuration it is given
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	}
+
+	transport := &http.Transport{
+		DialContext:           dial,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		ExpectContinueTimeout: options.ExpectContinueTimeout,
+		DisableKeepAlives:     options.DisableKeepAlives,
+	}
+
+	if mode == HTTP2Off {
+		return transport, nil, nil
+	}
+
+	// ConfigureTransports adds h2 to the ALPN protocols, a custom DialContext otherwise
+	// keeps net/http from doing so
+	if _, err := http2.ConfigureTransports(transport); err != nil {
+		return nil, nil, err
+	}
+
+	if mode != HTTP2PriorKnowledge {
+		return transport, nil, nil
+	}
+
+	h2c := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+		IdleConnTimeout: options.IdleConnTimeout,
+	}
+
+	return transport, h2c, nil
+}
+
+// h2cTransport sends http requests over cleartext HTTP/2 and https requests to base.
+type h2cTransport struct {
+	base http.RoundTripper
+	h2c  *http2.Transport
+}
+
+func (h *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return h.h2c.RoundTrip(req)
+	}
+
+	return h.base.RoundTrip(req)
+}
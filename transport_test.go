@@ -0,0 +1,227 @@
+package http_runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-proto", r.Proto)
+	})
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proto := func(t *testing.T, runner IHttpRunner, url string) string {
+		response, err := runner.GetHtml(NewHtmlRequestOptions(url))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return response.Header().Get("x-proto")
+	}
+
+	t.Run("TestTransport-H2C", func(t *testing.T) {
+		server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+		defer server.Close()
+
+		runner, err := NewHttpRunner(directDialer, WithHTTP2(HTTP2PriorKnowledge))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer runner.Close()
+
+		if got := proto(t, runner, server.URL); got != "HTTP/2.0" {
+			t.Errorf("proto = %v, want %v", got, "HTTP/2.0")
+		}
+
+		// ordered headers are written by the runner itself, over HTTP/1.1
+		requestOptions := NewHtmlRequestOptions(server.URL)
+		requestOptions.SetOrderedHeaders(NewHeaders("X-Test", "1"))
+
+		response, err := runner.GetHtml(requestOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.Header().Get("x-proto"); got != "HTTP/1.1" {
+			t.Errorf("proto with ordered headers = %v, want %v", got, "HTTP/1.1")
+		}
+	})
+	t.Run("TestTransport-HTTP2", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(handler)
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(server.Certificate())
+		tlsConfig := &tls.Config{RootCAs: rootCAs}
+
+		for mode, want := range map[HTTP2Mode]string{HTTP2Off: "HTTP/1.1", HTTP2Auto: "HTTP/2.0"} {
+			runner, err := NewHttpRunner(directDialer, WithTLSConfig(tlsConfig), WithHTTP2(mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := proto(t, runner, server.URL); got != want {
+				t.Errorf("proto with mode %v = %v, want %v", mode, got, want)
+			}
+
+			runner.Close()
+		}
+
+		if len(tlsConfig.NextProtos) > 0 {
+			t.Errorf("tlsConfig.NextProtos = %v, want the configuration unchanged", tlsConfig.NextProtos)
+		}
+	})
+	t.Run("TestTransport-Close", func(t *testing.T) {
+		var (
+			mutex  sync.Mutex
+			opened int
+			closed int
+		)
+
+		server := httptest.NewUnstartedServer(handler)
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			switch state {
+			case http.StateNew:
+				opened++
+			case http.StateClosed:
+				closed++
+			}
+		}
+		server.Start()
+		defer server.Close()
+
+		runner, err := NewHttpRunner(directDialer, WithTransportOptions(TransportOptions{MaxIdleConnsPerHost: 4}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 10; i++ {
+			proto(t, runner, server.URL)
+		}
+
+		mutex.Lock()
+		if opened != 1 {
+			t.Errorf("opened %v connections, want 1 reused", opened)
+		}
+		mutex.Unlock()
+
+		if err := runner.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for deadline := time.Now().Add(time.Second * 5); ; time.Sleep(time.Millisecond * 10) {
+			mutex.Lock()
+			done := closed == opened
+			mutex.Unlock()
+
+			if done {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("idle connection still open after Close()")
+			}
+		}
+	})
+	t.Run("TestTransport-Ordered", func(t *testing.T) {
+		var (
+			mutex  sync.Mutex
+			opened int
+			closed int
+		)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				time.Sleep(time.Millisecond * 200)
+			}
+			w.Write([]byte(r.Proto))
+		}))
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			switch state {
+			case http.StateNew:
+				opened++
+			case http.StateClosed:
+				closed++
+			}
+		}
+		server.Start()
+		defer server.Close()
+
+		count := func() (int, int) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			return opened, closed
+		}
+
+		runner, err := NewHttpRunner(directDialer, WithOrderedHeaders(NewHeaders("Accept", "*/*")),
+			WithTransportOptions(TransportOptions{MaxConnsPerHost: 1, ResponseHeaderTimeout: time.Millisecond * 100}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// ordered connections are reused, and shared under MaxConnsPerHost
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				response, err := runner.GetHtml(NewHtmlRequestOptions(server.URL))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := response.String(); got != "HTTP/1.1" {
+					t.Errorf("proto = %v, want %v", got, "HTTP/1.1")
+				}
+			}()
+		}
+		wg.Wait()
+
+		if opened, _ := count(); opened != 1 {
+			t.Errorf("opened %v connections, want 1 reused", opened)
+		}
+
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(server.URL + "/slow")); err == nil {
+			t.Error("slow response error = nil, want a response header timeout")
+		}
+
+		if _, err := runner.GetHtml(NewHtmlRequestOptions(server.URL)); err != nil {
+			t.Fatal(err)
+		}
+		if err := runner.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for deadline := time.Now().Add(time.Second * 5); ; time.Sleep(time.Millisecond * 10) {
+			if opened, closed := count(); opened == closed {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("idle ordered connection still open after Close()")
+			}
+		}
+	})
+}
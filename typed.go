@@ -0,0 +1,111 @@
+package http_runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DecodeError is returned by the typed helpers when a successful response can not be
+// decoded, Body keeps the raw response body.
+type DecodeError struct {
+	StatusCode int
+	Body       []byte
+	Err        error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding response with status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// GetJsonInto sends a GET request and decodes the response into T.
+func GetJsonInto[T any](runner IHttpRunner, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (T, error) {
+	return GetJsonIntoWithContext[T](context.Background(), runner, requestOptions, cookieJar...)
+}
+
+func GetJsonIntoWithContext[T any](ctx context.Context, runner IHttpRunner, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (T, error) {
+	return decodeJsonResponse[T](runner.GetJsonWithContext(ctx, requestOptions, cookieJar...))
+}
+
+// DeleteJsonInto sends a DELETE request and decodes the response into T.
+func DeleteJsonInto[T any](runner IHttpRunner, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (T, error) {
+	return DeleteJsonIntoWithContext[T](context.Background(), runner, requestOptions, cookieJar...)
+}
+
+func DeleteJsonIntoWithContext[T any](ctx context.Context, runner IHttpRunner, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (T, error) {
+	return decodeJsonResponse[T](runner.DeleteJsonWithContext(ctx, requestOptions, cookieJar...))
+}
+
+// PostJsonAs marshals body as the value of requestOptions, sends a POST request and
+// decodes the response into Resp.
+func PostJsonAs[Req, Resp any](runner IHttpRunner, requestOptions IJsonRequestOptions, body Req, cookieJar ...*http.Cookie) (Resp, error) {
+	return PostJsonAsWithContext[Req, Resp](context.Background(), runner, requestOptions, body, cookieJar...)
+}
+
+func PostJsonAsWithContext[Req, Resp any](ctx context.Context, runner IHttpRunner, requestOptions IJsonRequestOptions, body Req, cookieJar ...*http.Cookie) (Resp, error) {
+	return sendJsonAs[Req, Resp](ctx, runner.PostJsonWithContext, requestOptions, body, cookieJar)
+}
+
+// PutJsonAs marshals body as the value of requestOptions, sends a PUT request and
+// decodes the response into Resp.
+func PutJsonAs[Req, Resp any](runner IHttpRunner, requestOptions IJsonRequestOptions, body Req, cookieJar ...*http.Cookie) (Resp, error) {
+	return PutJsonAsWithContext[Req, Resp](context.Background(), runner, requestOptions, body, cookieJar...)
+}
+
+func PutJsonAsWithContext[Req, Resp any](ctx context.Context, runner IHttpRunner, requestOptions IJsonRequestOptions, body Req, cookieJar ...*http.Cookie) (Resp, error) {
+	return sendJsonAs[Req, Resp](ctx, runner.PutJsonWithContext, requestOptions, body, cookieJar)
+}
+
+// PatchJsonAs marshals body as the value of requestOptions, sends a PATCH request and
+// decodes the response into Resp.
+func PatchJsonAs[Req, Resp any](runner IHttpRunner, requestOptions IJsonRequestOptions, body Req, cookieJar ...*http.Cookie) (Resp, error) {
+	return PatchJsonAsWithContext[Req, Resp](context.Background(), runner, requestOptions, body, cookieJar...)
+}
+
+func PatchJsonAsWithContext[Req, Resp any](ctx context.Context, runner IHttpRunner, requestOptions IJsonRequestOptions, body Req, cookieJar ...*http.Cookie) (Resp, error) {
+	return sendJsonAs[Req, Resp](ctx, runner.PatchJsonWithContext, requestOptions, body, cookieJar)
+}
+
+type jsonMethod func(ctx context.Context, requestOptions IJsonRequestOptions, cookieJar ...*http.Cookie) (*resty.Response, error)
+
+func sendJsonAs[Req, Resp any](ctx context.Context, send jsonMethod, requestOptions IJsonRequestOptions, body Req, cookieJar []*http.Cookie) (Resp, error) {
+	value, err := json.Marshal(body)
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+
+	requestOptions.SetValue(value)
+
+	return decodeJsonResponse[Resp](send(ctx, requestOptions, cookieJar...))
+}
+
+// decodeJsonResponse decodes a 2xx response into T, an empty body leaves T at its zero value.
+func decodeJsonResponse[T any](response *resty.Response, err error) (T, error) {
+	var value T
+
+	if err != nil {
+		return value, err
+	}
+
+	if !response.IsSuccess() {
+		return value, newHttpError(response)
+	}
+
+	if len(response.Body()) <= 0 {
+		return value, nil
+	}
+
+	if err := json.Unmarshal(response.Body(), &value); err != nil {
+		return value, &DecodeError{StatusCode: response.StatusCode(), Body: response.Body(), Err: err}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,120 @@
+package http_runner
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	NetworkRunner "github.com/Tanreon/go-network-runner"
+)
+
+type testUser struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTypedJson(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`{"id":1,"name":"test"}`))
+				return
+			}
+
+			// echo the user back with a new id
+			var user testUser
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &user); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			user.Id = 2
+
+			_ = json.NewEncoder(w).Encode(user)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/broken":
+			_, _ = w.Write([]byte(`{"id":`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	directDialer, err := NetworkRunner.NewDirectDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewHttpRunner(directDialer, WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestTypedJson-GetJsonInto", func(t *testing.T) {
+		user, err := GetJsonInto[testUser](runner, NewJsonRequestOptions("/user"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (testUser{Id: 1, Name: "test"}); user != want {
+			t.Errorf("GetJsonInto() = %+v, want %+v", user, want)
+		}
+	})
+	t.Run("TestTypedJson-PostJsonAs", func(t *testing.T) {
+		for name, send := range map[string]func() (testUser, error){
+			"Post": func() (testUser, error) {
+				return PostJsonAs[testUser, testUser](runner, NewJsonRequestOptions("/user"), testUser{Name: "new"})
+			},
+			"Put": func() (testUser, error) {
+				return PutJsonAs[testUser, testUser](runner, NewJsonRequestOptions("/user"), testUser{Name: "new"})
+			},
+			"Patch": func() (testUser, error) {
+				return PatchJsonAs[testUser, testUser](runner, NewJsonRequestOptions("/user"), testUser{Name: "new"})
+			},
+		} {
+			user, err := send()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (testUser{Id: 2, Name: "new"}); user != want {
+				t.Errorf("%vJsonAs() = %+v, want %+v", name, user, want)
+			}
+		}
+	})
+	t.Run("TestTypedJson-Empty", func(t *testing.T) {
+		user, err := DeleteJsonInto[testUser](runner, NewJsonRequestOptions("/empty"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != (testUser{}) {
+			t.Errorf("DeleteJsonInto() = %+v, want the zero value", user)
+		}
+	})
+	t.Run("TestTypedJson-HttpError", func(t *testing.T) {
+		_, err := GetJsonInto[testUser](runner, NewJsonRequestOptions("/missing"))
+
+		var httpErr *HttpError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("GetJsonInto() error = %v, want *HttpError", err)
+		}
+		if httpErr.StatusCode != http.StatusNotFound || string(httpErr.Body) != `{"error":"not found"}` {
+			t.Errorf("HttpError = %+v, want the status and body of the response", httpErr)
+		}
+	})
+	t.Run("TestTypedJson-DecodeError", func(t *testing.T) {
+		_, err := GetJsonInto[testUser](runner, NewJsonRequestOptions("/broken"))
+
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("GetJsonInto() error = %v, want *DecodeError", err)
+		}
+		if string(decodeErr.Body) != `{"id":` {
+			t.Errorf("DecodeError.Body = %s, want the raw body", decodeErr.Body)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package http_runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultEchoUrl answers with the ip and the headers of the request, see ParseEcho.
+var DefaultEchoUrl = "https://httpbin.org/get"
+
+// leakHeaders are the headers proxies add that tell the target about the client behind them.
+var leakHeaders = []string{"X-Forwarded-For", "Via", "Forwarded", "X-Real-Ip", "Client-Ip"}
+
+// EchoInfo is what an ip echo endpoint saw of a request. Country and City are set by
+// endpoints with geo data.
+type EchoInfo struct {
+	Ip      string
+	Country string
+	City    string
+	Headers http.Header
+}
+
+// ParseEcho reads the JSON of httpbin.org/get and of endpoints like it: the ip from "origin",
+// "ip" or "query", the headers from "headers", the geo data from "country", "country_code",
+// "countryCode" and "city". Of a comma separated origin the last ip is the one connecting.
+func ParseEcho(body []byte) (EchoInfo, error) {
+	var echo struct {
+		Origin       string            `json:"origin"`
+		Ip           string            `json:"ip"`
+		Query        string            `json:"query"`
+		Headers      map[string]string `json:"headers"`
+		Country      string            `json:"country"`
+		CountryCode  string            `json:"country_code"`
+		CountryCode2 string            `json:"countryCode"`
+		City         string            `json:"city"`
+	}
+	if err := json.Unmarshal(body, &echo); err != nil {
+		return EchoInfo{}, err
+	}
+
+	info := EchoInfo{Country: echo.Country, City: echo.City, Headers: http.Header{}}
+	for _, ip := range []string{echo.Origin, echo.Ip, echo.Query} {
+		if len(ip) > 0 {
+			origins := strings.Split(ip, ",")
+			info.Ip = strings.TrimSpace(origins[len(origins)-1])
+			break
+		}
+	}
+	if len(info.Country) <= 0 {
+		info.Country = echo.CountryCode + echo.CountryCode2
+	}
+	for key, value := range echo.Headers {
+		info.Headers.Set(key, value)
+	}
+
+	if len(info.Ip) <= 0 {
+		return info, errors.New("echo has no ip")
+	}
+
+	return info, nil
+}
+
+// VerifyOptions configures ProxyHttpRunner.Verify, zero fields take their defaults.
+type VerifyOptions struct {
+	// EchoUrl is DefaultEchoUrl by default.
+	EchoUrl string
+	// Parse is ParseEcho by default.
+	Parse func(body []byte) (EchoInfo, error)
+	// Direct finds the direct ip, a runner of NewDefaultDirectHttpRunner by default.
+	Direct IHttpRunner
+}
+
+// ForwarderReport is how a forwarder did at Verify.
+type ForwarderReport struct {
+	// Forwarder is the name of the forwarder of WithForwarders, empty for the dialer of the runner.
+	Forwarder string
+	EchoInfo
+	Latency time.Duration
+	// SameAsDirect is set when the exit ip is the direct ip.
+	SameAsDirect bool
+	// LeaksDirectIp is set when the direct ip shows anywhere in the echo.
+	LeaksDirectIp bool
+	// LeakedHeaders are the headers the forwarder added telling about the client behind it.
+	LeakedHeaders http.Header
+	Err           error
+}
+
+// Ok reports whether the forwarder works and hides the direct ip.
+func (f ForwarderReport) Ok() bool {
+	return f.Err == nil && !f.SameAsDirect && !f.LeaksDirectIp && len(f.LeakedHeaders) <= 0
+}
+
+// VerifyReport is the result of Verify.
+type VerifyReport struct {
+	DirectIp   string
+	Forwarders []ForwarderReport
+}
+
+// Ok reports whether every forwarder is ok.
+func (v *VerifyReport) Ok() bool {
+	for _, forwarder := range v.Forwarders {
+		if !forwarder.Ok() {
+			return false
+		}
+	}
+
+	return len(v.Forwarders) > 0
+}
+
+// Verify calls the echo endpoint directly and through every forwarder of WithForwarders, or
+// through the dialer of the runner without them, and reports the exit ip of each forwarder,
+// its latency and what it leaks of the direct ip. Requests are not retried. The error is only
+// set when the direct ip can not be found, failed forwarders set the Err of their report.
+func (p *ProxyHttpRunner) Verify(ctx context.Context, options VerifyOptions) (*VerifyReport, error) {
+	if len(options.EchoUrl) <= 0 {
+		options.EchoUrl = DefaultEchoUrl
+	}
+	if options.Parse == nil {
+		options.Parse = ParseEcho
+	}
+	if options.Direct == nil {
+		direct, err := NewDefaultDirectHttpRunner()
+		if err != nil {
+			return nil, err
+		}
+		defer direct.Close()
+
+		options.Direct = direct
+	}
+
+	directInfo, _, _, err := echo(ctx, options.Direct, options, nil)
+	if err != nil {
+		return nil, fmt.Errorf("finding the direct ip: %w", err)
+	}
+
+	report := &VerifyReport{DirectIp: directInfo.Ip}
+
+	type target struct {
+		name string
+		pin  func(requestOptions IJsonRequestOptions)
+	}
+
+	targets := []target{{}}
+	if forwarders := p.transports.forwarders; len(forwarders) > 0 {
+		targets = targets[:0]
+		for i, forwarder := range forwarders {
+			index := i
+			targets = append(targets, target{name: forwarder.Name, pin: func(requestOptions IJsonRequestOptions) { requestOptions.SetForwarderIndex(index) }})
+		}
+	}
+
+	for _, target := range targets {
+		info, body, latency, err := echo(ctx, p, options, target.pin)
+
+		forwarderReport := ForwarderReport{Forwarder: target.name, EchoInfo: info, Latency: latency, Err: err}
+		if err == nil {
+			directIp := net.ParseIP(directInfo.Ip)
+			forwarderReport.SameAsDirect = info.Ip == directInfo.Ip || (directIp != nil && directIp.Equal(net.ParseIP(info.Ip)))
+			forwarderReport.LeaksDirectIp = !forwarderReport.SameAsDirect && directIp != nil && containsIp(body, directIp)
+
+			for _, key := range leakHeaders {
+				// the direct request shows which headers are the endpoint's own
+				if values := info.Headers.Values(key); len(values) > 0 && len(directInfo.Headers.Values(key)) <= 0 {
+					if forwarderReport.LeakedHeaders == nil {
+						forwarderReport.LeakedHeaders = http.Header{}
+					}
+					forwarderReport.LeakedHeaders[key] = values
+				}
+			}
+		}
+
+		report.Forwarders = append(report.Forwarders, forwarderReport)
+	}
+
+	return report, nil
+}
+
+// containsIp reports whether ip is one of the addresses in body, with or without a port. Every
+// run of characters an address is written with is parsed, so 1.2.3.4 is not found in 11.2.3.45.
+func containsIp(body []byte, ip net.IP) bool {
+	tokens := strings.FieldsFunc(string(body), func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F' || r == '.' || r == ':')
+	})
+
+	for _, token := range tokens {
+		candidates := []string{token, strings.TrimRight(token, ".")}
+		if host, _, err := net.SplitHostPort(token); err == nil {
+			candidates = append(candidates, host)
+		}
+
+		for _, candidate := range candidates {
+			if ip.Equal(net.ParseIP(candidate)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// echo calls the echo endpoint with runner once, pin choosing the forwarder.
+func echo(ctx context.Context, runner IHttpRunner, options VerifyOptions, pin func(requestOptions IJsonRequestOptions)) (EchoInfo, []byte, time.Duration, error) {
+	requestOptions := NewJsonRequestOptions(options.EchoUrl)
+	requestOptions.SetRetryOption(0)
+	if pin != nil {
+		pin(requestOptions)
+	}
+
+	startedAt := time.Now()
+	response, err := runner.GetJsonWithContext(ctx, requestOptions)
+	latency := time.Since(startedAt)
+	if err != nil {
+		return EchoInfo{}, nil, latency, err
+	}
+	if !response.IsSuccess() {
+		return EchoInfo{}, response.Body(), latency, newHttpError(response)
+	}
+
+	info, err := options.Parse(response.Body())
+
+	return info, response.Body(), latency, err
+}
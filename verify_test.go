@@ -0,0 +1,114 @@
+package http_runner
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nadoo/glider/rule"
+)
+
+func TestVerify(t *testing.T) {
+	echoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+		headers := map[string]string{}
+		for key := range r.Header {
+			headers[key] = r.Header.Get(key)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"origin": ip, "headers": headers, "country": "ZZ"})
+	}))
+	defer echoServer.Close()
+
+	good := startConnectProxy(t, &connectProxy{localIp: "127.0.0.2"})
+	leaking := startConnectProxy(t, &connectProxy{localIp: "127.0.0.3", leak: true})
+	transparent := startConnectProxy(t, &connectProxy{})
+
+	var forwarders []Forwarder
+	for name, proxy := range map[string]*connectProxy{"good": good, "leaking": leaking, "transparent": transparent} {
+		forwarder, err := rule.ForwarderFromURL(proxy.url(), "", time.Second*3, time.Second*3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forwarders = append(forwarders, Forwarder{Name: name, Dialer: forwarder})
+	}
+
+	runner, err := NewProxyHttpRunner(rule.NewProxy(nil, &rule.Strategy{Strategy: "rr"}, nil), WithForwarders(forwarders...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := runner.(*ProxyHttpRunner).Verify(context.Background(), VerifyOptions{EchoUrl: echoServer.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.DirectIp != "127.0.0.1" {
+		t.Errorf("DirectIp = %v, want %v", report.DirectIp, "127.0.0.1")
+	}
+	if report.Ok() {
+		t.Error("report.Ok() = true with a leaking and a transparent forwarder")
+	}
+	if len(report.Forwarders) != 3 {
+		t.Fatalf("got %v forwarder reports, want 3", len(report.Forwarders))
+	}
+
+	for _, forwarderReport := range report.Forwarders {
+		if forwarderReport.Err != nil {
+			t.Errorf("forwarder %v: %v", forwarderReport.Forwarder, forwarderReport.Err)
+			continue
+		}
+		if forwarderReport.Country != "ZZ" || forwarderReport.Latency <= 0 {
+			t.Errorf("forwarder %v: Country = %v, Latency = %v", forwarderReport.Forwarder, forwarderReport.Country, forwarderReport.Latency)
+		}
+
+		switch forwarderReport.Forwarder {
+		case "good":
+			if !forwarderReport.Ok() || forwarderReport.Ip != "127.0.0.2" {
+				t.Errorf("good forwarder: %+v", forwarderReport)
+			}
+		case "leaking":
+			if forwarderReport.Ip != "127.0.0.3" || !forwarderReport.LeaksDirectIp || len(forwarderReport.LeakedHeaders.Values("Via")) <= 0 || len(forwarderReport.LeakedHeaders.Values("X-Forwarded-For")) <= 0 {
+				t.Errorf("leaking forwarder: %+v", forwarderReport)
+			}
+		case "transparent":
+			if !forwarderReport.SameAsDirect {
+				t.Errorf("transparent forwarder: %+v", forwarderReport)
+			}
+		}
+	}
+}
+
+func TestContainsIp(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ip   string
+		want bool
+	}{
+		{"Exact", `{"origin": "1.2.3.4"}`, "1.2.3.4", true},
+		{"Longer", `{"origin": "11.2.3.45"}`, "1.2.3.4", false},
+		{"Prefix", `{"origin": "1.2.3.45"}`, "1.2.3.4", false},
+		{"List", `{"X-Forwarded-For": "10.0.0.1, 1.2.3.4"}`, "1.2.3.4", true},
+		{"Port", `{"Forwarded": "for=1.2.3.4:5678"}`, "1.2.3.4", true},
+		{"Sentence", `your ip is 1.2.3.4.`, "1.2.3.4", true},
+		{"Ipv6", `{"Forwarded": "for=\"[2001:db8::1]:443\""}`, "2001:db8::1", true},
+		{"Ipv6Expanded", `{"origin": "2001:0db8:0000:0000:0000:0000:0000:0001"}`, "2001:db8::1", true},
+		{"Ipv6Other", `{"origin": "2001:db8::10"}`, "2001:db8::1", false},
+		{"Ipv4Mapped", `{"origin": "::ffff:1.2.3.4"}`, "1.2.3.4", true},
+	}
+
+	for _, tt := range tests {
+		t.Run("TestContainsIp-"+tt.name, func(t *testing.T) {
+			if got := containsIp([]byte(tt.body), net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("containsIp(%s, %v) = %v, want %v", tt.body, tt.ip, got, tt.want)
+			}
+		})
+	}
+}